@@ -0,0 +1,265 @@
+package httpie
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The struct tags Bind reads, in the order they are checked
+var bindSources = []string{"path", "query", "header", "cookie"}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Bind fills a struct from the request using the path, query, header and cookie struct tags and then validates it
+//
+//	type ListUsers struct {
+//		OrgID  int      `path:"org"`
+//		Limit  int      `query:"limit" validate:"max=100"`
+//		Tags   []string `query:"tag"`
+//		Tenant string   `header:"X-Tenant"`
+//	}
+//
+// Fields without a value in the request are left untouched so defaults can be set beforehand.
+// Every conversion failure is reported in a single ErrHttpValidation keyed by the tag name.
+// The struct is then validated with the validator from GetValidator, its failures are keyed by the tag name too
+// and have messages in the language from the Accept-Language header if the client sent one.
+func Bind[T any](r *http.Request, data *T) error {
+	value := reflect.ValueOf(data).Elem()
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("httpie: Bind requires a struct, got %T", data)
+	}
+
+	validations := map[string]string{}
+	bindStruct(r, r.URL.Query(), value, validations)
+	if len(validations) > 0 {
+		return NewErrHttpValidation(validations)
+	}
+	v := GetValidator(r.Context())
+	err := v.ValidateCtx(r.Context(), value.Addr().Interface(), parseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+	if validationErr, ok := err.(ErrHttpValidation); ok {
+		// The validator names fields the same way as for a JSON body, the client sent the tag names
+		names := map[string]string{}
+		bindNames(v.tagName, value.Type(), "", names)
+		return validationErr.renameFields(names)
+	}
+	return err
+}
+
+// Map the name the validator gives each bound field to its tag name, embedded structs are part of the validator's path
+func bindNames(tagName func(reflect.StructField) string, valueType reflect.Type, prefix string, names map[string]string) {
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && !hasBindTag(field) {
+			bindNames(tagName, field.Type, prefix+tagName(field)+".", names)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name := bindTagName(field); name != "" {
+			names[prefix+tagName(field)] = name
+		}
+	}
+}
+
+// The name of the first bind tag of a field, empty if it has none
+func bindTagName(field reflect.StructField) string {
+	for _, source := range bindSources {
+		name := strings.SplitN(field.Tag.Get(source), ",", 2)[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Rename the fields of the failures and messages, fields without a new name are kept
+func (e ErrHttpValidation) renameFields(names map[string]string) ErrHttpValidation {
+	rename := func(fields map[string][]string) map[string][]string {
+		if fields == nil {
+			return nil
+		}
+		renamed := make(map[string][]string, len(fields))
+		for field, values := range fields {
+			if name, ok := names[field]; ok {
+				field = name
+			}
+			renamed[field] = values
+		}
+		return renamed
+	}
+	e.validationErrors = rename(e.validationErrors)
+	e.messages = rename(e.messages)
+	return e
+}
+
+func bindStruct(r *http.Request, query url.Values, value reflect.Value, validations map[string]string) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		fieldValue := value.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && !hasBindTag(field) {
+			bindStruct(r, query, fieldValue, validations)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		for _, source := range bindSources {
+			name := strings.SplitN(field.Tag.Get(source), ",", 2)[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			values := bindValues(r, query, source, name)
+			if len(values) == 0 {
+				continue
+			}
+			if err := bindField(fieldValue, values); err != nil {
+				validations[name] = "type=" + bindTypeName(field.Type)
+			}
+			break
+		}
+	}
+}
+
+func hasBindTag(field reflect.StructField) bool {
+	for _, source := range bindSources {
+		if _, ok := field.Tag.Lookup(source); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the raw values for a tag in the request
+func bindValues(r *http.Request, query url.Values, source string, name string) []string {
+	switch source {
+	case "path":
+		if value := r.PathValue(name); value != "" {
+			return []string{value}
+		}
+	case "query":
+		return query[name]
+	case "header":
+		return r.Header.Values(name)
+	case "cookie":
+		if cookie, err := r.Cookie(name); err == nil {
+			return []string{cookie.Value}
+		}
+	}
+	return nil
+}
+
+func bindField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Pointer {
+		target := reflect.New(field.Type().Elem())
+		if err := bindField(target.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+	if field.Kind() == reflect.Slice && !isTextUnmarshaler(field) {
+		// A single value is split by commas, the same as GetQueryParamList
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := bindScalar(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return bindScalar(field, values[0])
+}
+
+func isTextUnmarshaler(field reflect.Value) bool {
+	return field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType)
+}
+
+func bindScalar(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		target := reflect.New(field.Type().Elem())
+		if err := bindScalar(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+	// Covers time.Time (RFC 3339) and any custom types
+	if isTextUnmarshaler(field) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		result, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(result)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(result)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		result, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(result)
+	case reflect.Float32, reflect.Float64:
+		result, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(result)
+	default:
+		return fmt.Errorf("httpie: unsupported bind type %s", field.Type())
+	}
+	return nil
+}
+
+// The type name reported in the validation error code
+func bindTypeName(fieldType reflect.Type) string {
+	for fieldType.Kind() == reflect.Pointer || fieldType.Kind() == reflect.Slice {
+		fieldType = fieldType.Elem()
+	}
+	switch fieldType {
+	case durationType:
+		return "duration"
+	case timeType:
+		return "time"
+	}
+	return fieldType.Kind().String()
+}
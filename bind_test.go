@@ -0,0 +1,162 @@
+package httpie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBindEmbedded struct {
+	Page int `query:"page"`
+}
+
+type testBindStruct struct {
+	testBindEmbedded
+	ID       int           `path:"id"`
+	Limit    int           `query:"limit" validate:"max=100"`
+	Active   bool          `query:"active"`
+	Ratio    float64       `query:"ratio"`
+	Since    time.Time     `query:"since"`
+	Timeout  time.Duration `query:"timeout"`
+	Tags     []string      `query:"tag"`
+	IDs      []uint        `query:"ids"`
+	Tenant   string        `header:"X-Tenant"`
+	Session  string        `cookie:"session"`
+	Optional *int          `query:"optional"`
+	Until    *time.Time    `query:"until"`
+	Default  string        `query:"default"`
+	ignored  string        `query:"ignored"`
+}
+
+func TestBindOk(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com/users/42?limit=10&active=true&ratio=0.5&since=2024-01-02T03:04:05Z&timeout=1m30s&tag=a&tag=b&ids=1,2,3&optional=7&until=2025-01-01T00:00:00Z&page=3&ignored=x", nil)
+	r.SetPathValue("id", "42")
+	r.Header.Set("X-Tenant", "acme")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	data := testBindStruct{Default: "keep"}
+	err := Bind(r, &data)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, data.ID)
+	assert.Equal(t, 10, data.Limit)
+	assert.True(t, data.Active)
+	assert.Equal(t, 0.5, data.Ratio)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), data.Since)
+	assert.Equal(t, 90*time.Second, data.Timeout)
+	assert.Equal(t, []string{"a", "b"}, data.Tags)
+	assert.Equal(t, []uint{1, 2, 3}, data.IDs)
+	assert.Equal(t, "acme", data.Tenant)
+	assert.Equal(t, "abc", data.Session)
+	assert.Equal(t, 7, *data.Optional)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), *data.Until)
+	assert.Equal(t, 3, data.Page)
+	assert.Equal(t, "keep", data.Default)
+	assert.Equal(t, "", data.ignored)
+}
+
+func TestBindConversionErrs(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com/users/abc?limit=ten&active=maybe&ratio=x&since=yesterday&timeout=soon&ids=1,x&optional=nope", nil)
+	r.SetPathValue("id", "abc")
+	var data testBindStruct
+	err := Bind(r, &data)
+	assert.Error(t, err)
+	validationErr, ok := err.(IErrHttpValidation)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"id":       "type=int",
+		"limit":    "type=int",
+		"active":   "type=bool",
+		"ratio":    "type=float64",
+		"since":    "type=time",
+		"timeout":  "type=duration",
+		"ids":      "type=uint",
+		"optional": "type=int",
+	}, validationErr.ValidationErrors())
+}
+
+func TestBindValidates(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com?limit=1000", nil)
+	var data testBindStruct
+	err := Bind(r, &data)
+	validationErr, ok := err.(IErrHttpValidation)
+	assert.True(t, ok)
+	// Conversion and validation failures use the same key
+	assert.Equal(t, map[string]string{"limit": "max=100"}, validationErr.ValidationErrors())
+
+	r = httptest.NewRequest("GET", "http://example.com?limit=many", nil)
+	validationErr, ok = Bind(r, &data).(IErrHttpValidation)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"limit": "type=int"}, validationErr.ValidationErrors())
+}
+
+func TestBindValidatesLang(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com?limit=1000", nil)
+	r.Header.Set("Accept-Language", "en-US")
+	var data testBindStruct
+	validationErr, ok := Bind(r, &data).(IErrHttpValidationMessages)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"limit": "Limit must be 100 or less"}, validationErr.ValidationMessages())
+}
+
+func TestBindValidatesNames(t *testing.T) {
+	t.Parallel()
+	type embedded struct {
+		Page int `json:"pageNumber" query:"page" validate:"max=10"`
+	}
+	type search struct {
+		embedded
+		Term   string `json:"searchTerm" query:"q" validate:"required"`
+		Tenant string `header:"X-Tenant" validate:"required"`
+	}
+	r := httptest.NewRequest("GET", "http://example.com?page=50", nil)
+	validationErr, ok := Bind(r, &search{}).(IErrHttpValidation)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"q":        "required",
+		"X-Tenant": "required",
+		"page":     "max=10",
+	}, validationErr.ValidationErrors())
+
+	// Validate keeps the json names for the same struct
+	validationErr = Validate(search{embedded: embedded{Page: 50}})
+	assert.Equal(t, map[string]string{
+		"searchTerm":          "required",
+		"Tenant":              "required",
+		"embedded.pageNumber": "max=10",
+	}, validationErr.ValidationErrors())
+}
+
+func TestBindPointer(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com?limit=5", nil)
+	var data *testBindStruct
+	err := Bind(r, &data)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, data.Limit)
+}
+
+func TestBindNotStruct(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	var data map[string]string
+	err := Bind(r, &data)
+	assert.Error(t, err)
+}
+
+func TestBindUnsupportedType(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com?value=1", nil)
+	var data struct {
+		Value map[string]string `query:"value"`
+	}
+	err := Bind(r, &data)
+	validationErr, ok := err.(IErrHttpValidation)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"value": "type=map"}, validationErr.ValidationErrors())
+}
@@ -0,0 +1,265 @@
+package httpie
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// BreachedPasswordStore looks up breached password hashes using k-anonymity
+// Lookup receives the first 5 characters of the uppercase hex SHA-1 of a password and returns
+// the remaining 35 characters of every matching hash with the number of times it was seen
+type BreachedPasswordStore interface {
+	Lookup(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// Options for the notbreached validation
+type BreachedPasswordOpts struct {
+	// Reject passwords when the store fails, by default passwords are accepted and the error is logged
+	FailClosed bool
+}
+
+// Set the store used by the notbreached validation of DefaultValidator
+func SetBreachedPasswordStore(store BreachedPasswordStore, opts ...BreachedPasswordOpts) {
+	DefaultValidator.SetBreachedPasswordStore(store, opts...)
+}
+
+// Set the store used by the notbreached validation
+func (v *HttpValidator) SetBreachedPasswordStore(store BreachedPasswordStore, opts ...BreachedPasswordOpts) {
+	v.breachedPasswordsMu.Lock()
+	defer v.breachedPasswordsMu.Unlock()
+	v.breachedPasswords = store
+	v.breachedPasswordsOpts = BreachedPasswordOpts{}
+	if len(opts) > 0 {
+		v.breachedPasswordsOpts = opts[0]
+	}
+}
+
+// Check if a password appears in the store at least minCount times
+func IsPasswordBreached(ctx context.Context, store BreachedPasswordStore, password string, minCount int) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := store.Lookup(ctx, hash[:5])
+	if err != nil {
+		return false, err
+	}
+	count, ok := suffixes[hash[5:]]
+	return ok && count >= max(minCount, 1), nil
+}
+
+// The notbreached validation, the optional tag parameter is the number of breaches needed to reject a password (notbreached=10)
+// A missing store or a bad parameter fails the field and is returned as an internal error like the errors of context-aware rules
+func (v *HttpValidator) notBreachedValidatorCtx(ctx context.Context, fl validator.FieldLevel) bool {
+	v.breachedPasswordsMu.RLock()
+	store, opts := v.breachedPasswords, v.breachedPasswordsOpts
+	v.breachedPasswordsMu.RUnlock()
+	if store == nil {
+		recordValidationRuleErr(ctx, validationRuleErr(fl, errors.New("no BreachedPasswordStore is set, see SetBreachedPasswordStore")))
+		return false
+	}
+	minCount := 1
+	if fl.Param() != "" {
+		var err error
+		minCount, err = strconv.Atoi(fl.Param())
+		if err != nil {
+			recordValidationRuleErr(ctx, validationRuleErr(fl, fmt.Errorf("bad parameter %q", fl.Param())))
+			return false
+		}
+	}
+	password := fl.Field().String()
+	if password == "" {
+		return true
+	}
+	breached, err := IsPasswordBreached(ctx, store, password, minCount)
+	if err != nil {
+		// A request that has gone away aborts validation instead of being treated as a store failure
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			recordValidationRuleErr(ctx, ctxErr)
+			return false
+		}
+		slog.ErrorContext(ctx, "httpie.notbreached", slog.Any("err", err), slog.Bool("failClosed", opts.FailClosed))
+		return !opts.FailClosed
+	}
+	return !breached
+}
+
+// FileBreachedPasswordStore reads a HIBP style dump of SHA-1 hashes ordered by hash (HASH:COUNT per line)
+// Lookups binary search the file so it is never loaded into memory
+type FileBreachedPasswordStore struct {
+	file *os.File
+	size int64
+}
+
+// Open a sorted hash dump, the store must be closed when no longer needed
+func NewFileBreachedPasswordStore(path string) (*FileBreachedPasswordStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileBreachedPasswordStore{file: file, size: info.Size()}, nil
+}
+
+// Close the underlying file
+func (s *FileBreachedPasswordStore) Close() error {
+	return s.file.Close()
+}
+
+func (s *FileBreachedPasswordStore) Lookup(ctx context.Context, prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+	// Find the smallest offset whose following line is not before the prefix, that line is the first candidate
+	lo, hi := int64(0), s.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := s.lineStart(mid)
+		if err != nil {
+			return nil, err
+		}
+		if start >= s.size {
+			hi = mid
+			continue
+		}
+		line, err := s.readLine(start)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ToUpper(line) >= prefix {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	start, err := s.lineStart(lo)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]int{}
+	scanner := bufio.NewScanner(io.NewSectionReader(s.file, start, s.size-start))
+	for scanner.Scan() {
+		hash, count, err := parseBreachedLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			continue
+		}
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		result[hash[len(prefix):]] = count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, ctx.Err()
+}
+
+// Offset of the first line starting at or after off
+func (s *FileBreachedPasswordStore) lineStart(off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, 64)
+	// The line starts after the first newline at or after off-1
+	for pos := off - 1; pos < s.size; pos += int64(len(buf)) {
+		n, err := s.file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+	return s.size, nil
+}
+
+// Read the line starting at off without its line ending
+func (s *FileBreachedPasswordStore) readLine(off int64) (string, error) {
+	line, err := bufio.NewReaderSize(io.NewSectionReader(s.file, off, s.size-off), 64).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Parse a HASH:COUNT line, the count defaults to 1 when missing
+func parseBreachedLine(line string) (string, int, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", 0, nil
+	}
+	hash, countText, found := strings.Cut(line, ":")
+	count := 1
+	if found {
+		var err error
+		count, err = strconv.Atoi(strings.TrimSpace(countText))
+		if err != nil {
+			return "", 0, fmt.Errorf("httpie: bad breached password line %q: %w", line, err)
+		}
+	}
+	return strings.ToUpper(hash), count, nil
+}
+
+// HttpBreachedPasswordStore queries a Pwned Passwords compatible range API (GET {BaseURL}/range/{prefix})
+type HttpBreachedPasswordStore struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+const PWNED_PASSWORDS_URL = "https://api.pwnedpasswords.com"
+
+// Create a store for the range API at baseURL, use PWNED_PASSWORDS_URL for the public service
+func NewHttpBreachedPasswordStore(baseURL string) *HttpBreachedPasswordStore {
+	return &HttpBreachedPasswordStore{BaseURL: strings.TrimRight(baseURL, "/"), Client: http.DefaultClient}
+}
+
+func (s *HttpBreachedPasswordStore) Lookup(ctx context.Context, prefix string) (map[string]int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.BaseURL+"/range/"+strings.ToUpper(prefix), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Padding hides the number of matches from anyone watching the response size
+	req.Header.Set("Add-Padding", "true")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("httpie: breached password lookup failed with status %d", resp.StatusCode)
+	}
+	result := map[string]int{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		suffix, count, err := parseBreachedLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		// Padding entries have a count of 0
+		if suffix == "" || count == 0 {
+			continue
+		}
+		result[suffix] = count
+	}
+	return result, scanner.Err()
+}
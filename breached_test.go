@@ -0,0 +1,203 @@
+package httpie
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBreachedStore map[string]int
+
+func (s testBreachedStore) Lookup(ctx context.Context, prefix string) (map[string]int, error) {
+	if prefix == testHash("storefailure")[:5] {
+		return nil, errors.New("store unavailable")
+	}
+	result := map[string]int{}
+	for hash, count := range s {
+		if strings.HasPrefix(hash, prefix) {
+			result[hash[5:]] = count
+		}
+	}
+	return result, nil
+}
+
+var testBreachedValidator = NewValidator(ValidatorOpts{
+	BreachedPasswords: testBreachedStore{
+		testHash("password123"): 250000,
+		testHash("rarely-seen"): 2,
+	},
+})
+
+func testHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Write a sorted dump with the given passwords and some filler hashes
+func testBreachedFile(t *testing.T, passwords map[string]int) string {
+	var lines []string
+	for password, count := range passwords {
+		lines = append(lines, fmt.Sprintf("%s:%d", testHash(password), count))
+	}
+	for i := range 500 {
+		lines = append(lines, fmt.Sprintf("%s:%d", testHash(fmt.Sprintf("filler-%d", i)), i+1))
+	}
+	slices.Sort(lines)
+	path := filepath.Join(t.TempDir(), "hashes.txt")
+	assert.Nil(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+	return path
+}
+
+func TestFileBreachedPasswordStore(t *testing.T) {
+	t.Parallel()
+	store, err := NewFileBreachedPasswordStore(testBreachedFile(t, map[string]int{"password123": 250000, "hunter2": 17}))
+	assert.Nil(t, err)
+	defer store.Close()
+
+	for password, count := range map[string]int{"password123": 250000, "hunter2": 17, "filler-0": 1, "filler-499": 500} {
+		hash := testHash(password)
+		result, err := store.Lookup(context.Background(), hash[:5])
+		assert.Nil(t, err)
+		assert.Equal(t, count, result[hash[5:]], password)
+		for suffix := range result {
+			assert.Len(t, suffix, 35)
+		}
+	}
+
+	breached, err := IsPasswordBreached(context.Background(), store, "hunter2", 1)
+	assert.Nil(t, err)
+	assert.True(t, breached)
+	breached, err = IsPasswordBreached(context.Background(), store, "hunter2", 100)
+	assert.Nil(t, err)
+	assert.False(t, breached)
+	breached, err = IsPasswordBreached(context.Background(), store, "correct-horse-battery-staple", 1)
+	assert.Nil(t, err)
+	assert.False(t, breached)
+
+	result, err := store.Lookup(context.Background(), "00000")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+	result, err = store.Lookup(context.Background(), "FFFFF")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+func TestFileBreachedPasswordStoreEdges(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	assert.Nil(t, os.WriteFile(path, nil, 0o600))
+	store, err := NewFileBreachedPasswordStore(path)
+	assert.Nil(t, err)
+	result, err := store.Lookup(context.Background(), "ABCDE")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+	store.Close()
+
+	// A single line without a trailing newline or count
+	path = filepath.Join(t.TempDir(), "single.txt")
+	assert.Nil(t, os.WriteFile(path, []byte(strings.ToLower(testHash("hunter2"))), 0o600))
+	store, err = NewFileBreachedPasswordStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+	breached, err := IsPasswordBreached(context.Background(), store, "hunter2", 1)
+	assert.Nil(t, err)
+	assert.True(t, breached)
+
+	_, err = NewFileBreachedPasswordStore(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.NotNil(t, err)
+}
+
+func TestHttpBreachedPasswordStore(t *testing.T) {
+	t.Parallel()
+	hash := testHash("hunter2")
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		assert.Equal(t, "true", r.Header.Get("Add-Padding"))
+		fmt.Fprintf(w, "%s:17\r\n0000000000000000000000000000000000A:0\r\n", hash[5:])
+	}))
+	defer server.Close()
+
+	store := NewHttpBreachedPasswordStore(server.URL + "/")
+	breached, err := IsPasswordBreached(context.Background(), store, "hunter2", 1)
+	assert.Nil(t, err)
+	assert.True(t, breached)
+	assert.Equal(t, "/range/"+hash[:5], requested)
+
+	result, err := store.Lookup(context.Background(), hash[:5])
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{hash[5:]: 17}, result)
+}
+
+func TestHttpBreachedPasswordStoreErr(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	_, err := NewHttpBreachedPasswordStore(server.URL).Lookup(context.Background(), "ABCDE")
+	assert.EqualError(t, err, "httpie: breached password lookup failed with status 429")
+}
+
+type testBreachedStruct struct {
+	Password string `json:"password" validate:"notbreached"`
+	Pin      string `json:"pin" validate:"omitempty,notbreached=10"`
+}
+
+func TestValidatorNotBreached(t *testing.T) {
+	t.Parallel()
+	err := testBreachedValidator.Validate(testBreachedStruct{Password: "password123", Pin: "rarely-seen"})
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]string{"password": "notbreached"}, err.ValidationErrors())
+
+	err = testBreachedValidator.Validate(testBreachedStruct{Password: "correct-horse-battery-staple"})
+	assert.Nil(t, err)
+
+	// Store failures are logged and the password is accepted
+	err = testBreachedValidator.Validate(testBreachedStruct{Password: "storefailure"})
+	assert.Nil(t, err)
+
+	err = testBreachedValidator.ValidateLang(testBreachedStruct{Password: "password123"}, "en")
+	assert.Equal(t, map[string]string{"password": "password has appeared in a data breach, choose a different password"}, err.(IErrHttpValidationMessages).ValidationMessages())
+}
+
+func TestValidatorNotBreachedRuleErr(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	err := NewValidator().ValidateCtx(ctx, testBreachedStruct{Password: "hunter2"})
+	assert.EqualError(t, err, "httpie: validation notbreached on password: no BreachedPasswordStore is set, see SetBreachedPasswordStore")
+	assert.Equal(t, 500, MapErr(err).StatusCode())
+
+	err = testBreachedValidator.ValidateCtx(ctx, struct {
+		Password string `json:"password" validate:"notbreached=many"`
+	}{Password: "hunter2"})
+	assert.EqualError(t, err, `httpie: validation notbreached on password: bad parameter "many"`)
+}
+
+func TestValidatorNotBreachedCancelled(t *testing.T) {
+	t.Parallel()
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+	v := NewValidator(ValidatorOpts{BreachedPasswords: NewHttpBreachedPasswordStore(server.URL)})
+
+	// The lookup uses the request context, so a request that has gone away is not accepted as a store failure
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := v.ValidateCtx(ctx, testBreachedStruct{Password: "hunter2"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, requested)
+}
@@ -0,0 +1,410 @@
+package httpie
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder writes a value to a response body in a specific media type
+type Encoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+// Decoder reads a value from a request body in a specific media type
+type Decoder interface {
+	Decode(r io.Reader, v any) error
+}
+
+// EncoderFunc adapts a function to the Encoder interface
+type EncoderFunc func(w io.Writer, v any) error
+
+func (f EncoderFunc) Encode(w io.Writer, v any) error {
+	return f(w, v)
+}
+
+// DecoderFunc adapts a function to the Decoder interface
+type DecoderFunc func(r io.Reader, v any) error
+
+func (f DecoderFunc) Decode(r io.Reader, v any) error {
+	return f(r, v)
+}
+
+type mediaEncoder struct {
+	mediaType string
+	encoder   Encoder
+}
+
+// Codecs is a registry of encoders and decoders keyed by media type
+type Codecs struct {
+	mu       sync.RWMutex
+	encoders []mediaEncoder
+	decoders map[string]Decoder
+	fallback string
+}
+
+// Create an empty codec registry, the first registered encoder and decoder are used as the defaults
+func NewCodecs() *Codecs {
+	return &Codecs{decoders: map[string]Decoder{}}
+}
+
+// Register an encoder for a media type, replacing any existing encoder for it
+func (c *Codecs) RegisterEncoder(mediaType string, encoder Encoder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mediaType = strings.ToLower(mediaType)
+	for i := range c.encoders {
+		if c.encoders[i].mediaType == mediaType {
+			c.encoders[i].encoder = encoder
+			return
+		}
+	}
+	c.encoders = append(c.encoders, mediaEncoder{mediaType, encoder})
+}
+
+// Register a decoder for a media type, replacing any existing decoder for it
+func (c *Codecs) RegisterDecoder(mediaType string, decoder Decoder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mediaType = strings.ToLower(mediaType)
+	if len(c.decoders) == 0 {
+		c.fallback = mediaType
+	}
+	c.decoders[mediaType] = decoder
+}
+
+// Negotiate the encoder to use for an Accept header value, honouring q-values
+// An empty header selects the first registered encoder, ErrNotAcceptable is returned if nothing matches
+func (c *Codecs) Negotiate(accept string) (string, Encoder, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.encoders) == 0 {
+		return "", nil, ErrNotAcceptable
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return c.encoders[0].mediaType, c.encoders[0].encoder, nil
+	}
+	best := -1
+	bestQ := 0.0
+	bestPosition := 0
+	for i, candidate := range c.encoders {
+		q, position := matchAccept(ranges, candidate.mediaType)
+		if q <= 0 {
+			continue
+		}
+		if best == -1 || q > bestQ || (q == bestQ && position < bestPosition) {
+			best, bestQ, bestPosition = i, q, position
+		}
+	}
+	if best == -1 {
+		return "", nil, ErrNotAcceptable
+	}
+	return c.encoders[best].mediaType, c.encoders[best].encoder, nil
+}
+
+// Find the decoder for a Content-Type header value
+// An empty header selects the first registered decoder, ErrUnsupportedMediaType is returned if nothing matches
+func (c *Codecs) Decoder(contentType string) (Decoder, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if contentType == "" {
+		if decoder, ok := c.decoders[c.fallback]; ok {
+			return decoder, nil
+		}
+		return nil, ErrUnsupportedMediaType
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	decoder, ok := c.decoders[mediaType]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+	return decoder, nil
+}
+
+// The registered encoder media types in order of preference
+func (c *Codecs) MediaTypes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]string, len(c.encoders))
+	for i, e := range c.encoders {
+		result[i] = e.mediaType
+	}
+	return result
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// Parse an Accept header into its media ranges, ignoring anything malformed
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType, q})
+	}
+	return ranges
+}
+
+// Find the q-value of the most specific range matching the media type and its position in the header
+func matchAccept(ranges []acceptRange, mediaType string) (float64, int) {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	specificity := -1
+	q := 0.0
+	position := 0
+	for i, r := range ranges {
+		var s int
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case r.mediaType == mainType+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			specificity, q, position = s, r.q, i
+		}
+	}
+	return q, position
+}
+
+// Encodes values as JSON
+var JsonEncoder = EncoderFunc(func(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+})
+
+// Decodes values from JSON
+var JsonDecoder = DecoderFunc(func(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+})
+
+// Encodes values as XML, maps with string keys are written as entry elements under a response root
+var XmlEncoder = EncoderFunc(func(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	value := reflect.ValueOf(v)
+	switch {
+	case isStringMap(value):
+		return encoder.Encode(xmlMap{value})
+	case value.Kind() == reflect.Slice || value.Kind() == reflect.Array:
+		return encoder.Encode(xmlList{value})
+	default:
+		return encoder.Encode(v)
+	}
+})
+
+// Decodes values from XML
+var XmlDecoder = DecoderFunc(func(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+})
+
+func isStringMap(value reflect.Value) bool {
+	return value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String
+}
+
+// Writes a map as <response><entry key="...">value</entry></response>
+type xmlMap struct {
+	value reflect.Value
+}
+
+func (m xmlMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "response"
+	return encodeXmlMap(e, start, m.value)
+}
+
+func encodeXmlMap(e *xml.Encoder, start xml.StartElement, value reflect.Value) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := value.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+	for _, key := range keys {
+		entry := xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key.String()}},
+		}
+		item := reflect.Indirect(value.MapIndex(key))
+		if item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		var err error
+		if item.IsValid() && isStringMap(item) {
+			err = encodeXmlMap(e, entry, item)
+		} else if item.IsValid() {
+			err = e.EncodeElement(item.Interface(), entry)
+		} else {
+			err = e.EncodeElement("", entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Writes a slice under a single response root so the document stays well formed
+type xmlList struct {
+	value reflect.Value
+}
+
+func (l xmlList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "response"
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if l.value.Kind() == reflect.Array || !l.value.IsNil() {
+		for i := 0; i < l.value.Len(); i++ {
+			if err := e.Encode(l.value.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Encodes [][]string, structs and slices of structs as CSV with a header row
+// Column names come from the csv tag, then the json tag, then the field name
+var CsvEncoder = EncoderFunc(func(w io.Writer, v any) error {
+	writer := csv.NewWriter(w)
+	if records, ok := v.([][]string); ok {
+		return writer.WriteAll(records)
+	}
+	value := reflect.Indirect(reflect.ValueOf(v))
+	var rows []reflect.Value
+	var rowType reflect.Type
+	switch value.Kind() {
+	case reflect.Struct:
+		rows = []reflect.Value{value}
+		rowType = value.Type()
+	case reflect.Slice, reflect.Array:
+		rowType = value.Type().Elem()
+		if rowType.Kind() == reflect.Pointer {
+			rowType = rowType.Elem()
+		}
+		if rowType.Kind() != reflect.Struct {
+			return fmt.Errorf("csv: unsupported type %T", v)
+		}
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, value.Index(i))
+		}
+	default:
+		return fmt.Errorf("csv: unsupported type %T", v)
+	}
+
+	var header []string
+	var fields []int
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := csvFieldName(field)
+		if name == "-" {
+			continue
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(fields))
+	for _, row := range rows {
+		row = reflect.Indirect(row)
+		for i, field := range fields {
+			if !row.IsValid() {
+				record[i] = ""
+				continue
+			}
+			record[i] = csvFormat(row.Field(field))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+})
+
+func csvFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"csv", "json"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func csvFormat(value reflect.Value) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// The codec registry used by the negotiated read and write helpers
+// JSON is the default, XML and CSV are also available
+var DefaultCodecs = NewCodecs()
+
+func init() {
+	DefaultCodecs.RegisterEncoder("application/json", JsonEncoder)
+	DefaultCodecs.RegisterEncoder("application/xml", XmlEncoder)
+	DefaultCodecs.RegisterEncoder("text/xml", XmlEncoder)
+	DefaultCodecs.RegisterEncoder("text/csv", CsvEncoder)
+	DefaultCodecs.RegisterDecoder("application/json", JsonDecoder)
+	DefaultCodecs.RegisterDecoder("application/xml", XmlDecoder)
+	DefaultCodecs.RegisterDecoder("text/xml", XmlDecoder)
+}
+
+// Register an encoder for a media type with the default codecs
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	DefaultCodecs.RegisterEncoder(mediaType, encoder)
+}
+
+// Register a decoder for a media type with the default codecs
+func RegisterDecoder(mediaType string, decoder Decoder) {
+	DefaultCodecs.RegisterDecoder(mediaType, decoder)
+}
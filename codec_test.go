@@ -0,0 +1,155 @@
+package httpie
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCodecStruct struct {
+	Name    string    `json:"name"`
+	Age     int       `csv:"years"`
+	Secret  string    `json:"-" csv:"-"`
+	Created time.Time `json:"created"`
+	Note    *string
+}
+
+func TestNegotiateEmpty(t *testing.T) {
+	t.Parallel()
+	mediaType, encoder, err := DefaultCodecs.Negotiate("")
+	assert.NoError(t, err)
+	assert.NotNil(t, encoder)
+	assert.Equal(t, "application/json", mediaType)
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"application/xml":                       "application/xml",
+		"text/csv;q=0.5, application/xml;q=0.9": "application/xml",
+		"text/*":                                "text/xml",
+		"*/*":                                   "application/json",
+		"*/*;q=0.1, text/csv":                   "text/csv",
+		"*/*, application/json;q=0":             "application/xml",
+		"text/html, application/xhtml+xml, */*;q=0.8": "application/json",
+		"APPLICATION/XML":             "application/xml",
+		"text/csv, application/xml":   "text/csv",
+		"garbage;;;, application/xml": "application/xml",
+	}
+	for accept, expected := range tests {
+		mediaType, _, err := DefaultCodecs.Negotiate(accept)
+		assert.NoError(t, err, accept)
+		assert.Equal(t, expected, mediaType, accept)
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	t.Parallel()
+	_, _, err := DefaultCodecs.Negotiate("text/html, image/*;q=0.5")
+	assert.Equal(t, ErrNotAcceptable, err)
+	_, _, err = NewCodecs().Negotiate("")
+	assert.Equal(t, ErrNotAcceptable, err)
+}
+
+func TestCodecsDecoder(t *testing.T) {
+	t.Parallel()
+	decoder, err := DefaultCodecs.Decoder("")
+	assert.NoError(t, err)
+	assert.NotNil(t, decoder)
+	decoder, err = DefaultCodecs.Decoder("application/xml; charset=utf-8")
+	assert.NoError(t, err)
+	assert.NotNil(t, decoder)
+	_, err = DefaultCodecs.Decoder("text/csv")
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+	_, err = DefaultCodecs.Decoder("not a media type;")
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+	_, err = NewCodecs().Decoder("")
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+}
+
+func TestCodecsRegisterReplaces(t *testing.T) {
+	t.Parallel()
+	codecs := NewCodecs()
+	codecs.RegisterEncoder("text/plain", JsonEncoder)
+	codecs.RegisterEncoder("application/json", JsonEncoder)
+	codecs.RegisterEncoder("TEXT/PLAIN", EncoderFunc(func(w io.Writer, v any) error {
+		_, err := io.WriteString(w, "plain")
+		return err
+	}))
+	assert.Equal(t, []string{"text/plain", "application/json"}, codecs.MediaTypes())
+	_, encoder, err := codecs.Negotiate("")
+	assert.NoError(t, err)
+	var buffer bytes.Buffer
+	encoder.Encode(&buffer, nil)
+	assert.Equal(t, "plain", buffer.String())
+}
+
+func TestXmlEncoderMap(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	err := XmlEncoder.Encode(&buffer, map[string]any{"message": "validation failed", "errors": map[string]string{"items[0].name": "required"}})
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><entry key="errors"><entry key="items[0].name">required</entry></entry><entry key="message">validation failed</entry></response>`, buffer.String())
+}
+
+func TestXmlEncoderSlice(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	err := XmlEncoder.Encode(&buffer, []testStruct{{Name: "a"}, {Name: "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><testStruct><Name>a</Name></testStruct><testStruct><Name>b</Name></testStruct></response>`, buffer.String())
+}
+
+func TestXmlDecoder(t *testing.T) {
+	t.Parallel()
+	var data testStruct
+	err := XmlDecoder.Decode(strings.NewReader(`<testStruct><Name>hello</Name></testStruct>`), &data)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", data.Name)
+}
+
+func TestCsvEncoderStructs(t *testing.T) {
+	t.Parallel()
+	note := "hi, there"
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buffer bytes.Buffer
+	err := CsvEncoder.Encode(&buffer, []*testCodecStruct{
+		{Name: "a", Age: 1, Secret: "x", Created: created, Note: &note},
+		{Name: "b", Age: 2, Created: created},
+		nil,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "name,years,created,Note\n"+
+		"a,1,2024-01-02T03:04:05Z,\"hi, there\"\n"+
+		"b,2,2024-01-02T03:04:05Z,\n"+
+		",,,\n", buffer.String())
+}
+
+func TestCsvEncoderStruct(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	err := CsvEncoder.Encode(&buffer, testStruct{Name: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Name\na\n", buffer.String())
+}
+
+func TestCsvEncoderRecords(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	err := CsvEncoder.Encode(&buffer, [][]string{{"a", "b"}, {"1", "2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", buffer.String())
+}
+
+func TestCsvEncoderUnsupported(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	assert.Error(t, CsvEncoder.Encode(&buffer, map[string]string{"a": "b"}))
+	assert.Error(t, CsvEncoder.Encode(&buffer, []string{"a"}))
+}
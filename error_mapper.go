@@ -0,0 +1,83 @@
+package httpie
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// ErrMapper translates an error that is not an IErrHttp into one, returning false if it does not handle the error
+type ErrMapper func(err error) (IErrHttp, bool)
+
+var (
+	errMappersMu sync.RWMutex
+	// Held by pointer so a registration can be removed again
+	errMappers []*ErrMapper
+)
+
+func init() {
+	RegisterErrMapping(sql.ErrNoRows, ErrNotFound)
+	RegisterErrMapping(context.DeadlineExceeded, ErrGatewayTimeout)
+	RegisterErrMapping(context.Canceled, ErrClientClosedRequest)
+	RegisterErrTypeMapping[*json.SyntaxError](ErrBadRequest)
+	RegisterErrTypeMapping[*http.MaxBytesError](ErrRequestTooLarge)
+}
+
+// Register a mapper that WriteErr consults before falling back to ErrInternal
+// Mappers registered later take precedence, so the default mappings can be overridden
+// The returned function removes the mapper again, useful for tests and temporary overrides
+func RegisterErrMapper(mapper ErrMapper) (unregister func()) {
+	entry := &mapper
+	errMappersMu.Lock()
+	defer errMappersMu.Unlock()
+	errMappers = append(errMappers, entry)
+	return func() {
+		errMappersMu.Lock()
+		defer errMappersMu.Unlock()
+		errMappers = slices.DeleteFunc(errMappers, func(m *ErrMapper) bool { return m == entry })
+	}
+}
+
+// Register a mapping from a sentinel error (matched with errors.Is) to an http error
+// The original error is attached as the cause so it is still logged, the returned function removes the mapping
+func RegisterErrMapping(target error, httpErr ErrHttp) (unregister func()) {
+	return RegisterErrMapper(func(err error) (IErrHttp, bool) {
+		if errors.Is(err, target) {
+			return httpErr.Wrap(err), true
+		}
+		return nil, false
+	})
+}
+
+// Register a mapping from an error type (matched with errors.As) to an http error
+// The original error is attached as the cause so it is still logged, the returned function removes the mapping
+func RegisterErrTypeMapping[E error](httpErr ErrHttp) (unregister func()) {
+	return RegisterErrMapper(func(err error) (IErrHttp, bool) {
+		var target E
+		if errors.As(err, &target) {
+			return httpErr.Wrap(err), true
+		}
+		return nil, false
+	})
+}
+
+// Map an error to an IErrHttp, following wrapped and joined errors
+// An IErrHttp in the error tree always wins, then the registered mappers are consulted, and finally ErrInternal wrapping the error is returned
+func MapErr(err error) IErrHttp {
+	var httpErr IErrHttp
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	errMappersMu.RLock()
+	defer errMappersMu.RUnlock()
+	for i := len(errMappers) - 1; i >= 0; i-- {
+		if httpErr, ok := (*errMappers[i])(err); ok {
+			return httpErr
+		}
+	}
+	return ErrInternal.Wrap(err)
+}
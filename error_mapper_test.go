@@ -0,0 +1,109 @@
+package httpie
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMappedErr struct {
+	code string
+}
+
+func (e *testMappedErr) Error() string {
+	return "mapped " + e.code
+}
+
+var errTestSentinel = errors.New("test sentinel")
+
+func TestMapErrDefaults(t *testing.T) {
+	t.Parallel()
+	var syntaxErr error = &json.SyntaxError{}
+	body := http.MaxBytesReader(nil, io.NopCloser(strings.NewReader("too long")), 2)
+	_, maxBytesErr := io.ReadAll(body)
+	tests := []struct {
+		err    error
+		target ErrHttp
+	}{
+		{sql.ErrNoRows, ErrNotFound},
+		{fmt.Errorf("get user: %w", sql.ErrNoRows), ErrNotFound},
+		{context.DeadlineExceeded, ErrGatewayTimeout},
+		{context.Canceled, ErrClientClosedRequest},
+		{syntaxErr, ErrBadRequest},
+		{maxBytesErr, ErrRequestTooLarge},
+	}
+	for _, test := range tests {
+		httpErr := MapErr(test.err)
+		assert.ErrorIs(t, httpErr, test.target, test.err.Error())
+		assert.ErrorIs(t, httpErr, test.err, test.err.Error())
+	}
+}
+
+func TestMapErrHttpErrWins(t *testing.T) {
+	t.Parallel()
+	err := errors.Join(sql.ErrNoRows, ErrConflict)
+	assert.Equal(t, ErrConflict, MapErr(err))
+}
+
+func TestMapErrFallback(t *testing.T) {
+	t.Parallel()
+	err := errors.New("unknown")
+	httpErr := MapErr(err)
+	assert.ErrorIs(t, httpErr, ErrInternal)
+	assert.ErrorIs(t, httpErr, err)
+	assert.Equal(t, http.StatusInternalServerError, httpErr.StatusCode())
+}
+
+func TestRegisterErrMapping(t *testing.T) {
+	t.Parallel()
+	t.Cleanup(RegisterErrMapping(errTestSentinel, ErrForbidden))
+	w := httptest.NewRecorder()
+	WriteErr(w, fmt.Errorf("wrapped: %w", errTestSentinel))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"message":"forbidden"}`, w.Body.String())
+}
+
+func TestRegisterErrTypeMapping(t *testing.T) {
+	t.Parallel()
+	t.Cleanup(RegisterErrTypeMapping[*testMappedErr](ErrConflict))
+	httpErr := MapErr(fmt.Errorf("wrapped: %w", &testMappedErr{"a"}))
+	assert.ErrorIs(t, httpErr, ErrConflict)
+	var target *testMappedErr
+	assert.True(t, errors.As(httpErr, &target))
+	assert.Equal(t, "a", target.code)
+}
+
+func TestRegisterErrMapper(t *testing.T) {
+	t.Parallel()
+	t.Cleanup(RegisterErrMapper(func(err error) (IErrHttp, bool) {
+		if strings.HasPrefix(err.Error(), "teapot:") {
+			return NewErrHttp(http.StatusTeapot, "teapot"), true
+		}
+		return nil, false
+	}))
+	w := httptest.NewRecorder()
+	WriteErr(w, errors.New("teapot: short and stout"))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.JSONEq(t, `{"message":"teapot"}`, w.Body.String())
+}
+
+func TestUnregisterErrMapper(t *testing.T) {
+	t.Parallel()
+	err := errors.New("unregistered")
+	unregister := RegisterErrMapping(err, ErrConflict)
+	assert.ErrorIs(t, MapErr(err), ErrConflict)
+	unregister()
+	assert.ErrorIs(t, MapErr(err), ErrInternal)
+	// Calling it again is harmless
+	unregister()
+	assert.ErrorIs(t, MapErr(sql.ErrNoRows), ErrNotFound)
+}
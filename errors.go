@@ -1,6 +1,7 @@
 package httpie
 
 import (
+	"maps"
 	"net/http"
 )
 
@@ -15,9 +16,47 @@ type IErrHttpValidation interface {
 	ValidationErrors() map[string]string
 }
 
+// Validation errors which can report more than one failure per field
+type IErrHttpValidationList interface {
+	StatusCode() int
+	Error() string
+	ValidationErrors() map[string]string
+	ValidationErrorList() map[string][]string
+}
+
+// Validation errors which carry human readable messages for each field
+type IErrHttpValidationMessages interface {
+	StatusCode() int
+	Error() string
+	ValidationMessages() map[string]string
+	ValidationMessageList() map[string][]string
+}
+
+// Errors which carry the optional RFC 9457 problem details members
+type IErrHttpProblem interface {
+	StatusCode() int
+	Error() string
+	Type() string
+	Title() string
+	Detail() string
+	Instance() string
+	Extensions() map[string]any
+}
+
 type ErrHttp struct {
 	statusCode int
 	error      string
+	details    *errHttpDetails
+}
+
+// Optional problem details members, kept behind a pointer so ErrHttp stays comparable
+type errHttpDetails struct {
+	problemType string
+	title       string
+	detail      string
+	instance    string
+	extensions  map[string]any
+	cause       error
 }
 
 func (e ErrHttp) StatusCode() int {
@@ -28,21 +67,222 @@ func (e ErrHttp) Error() string {
 	return e.error
 }
 
+// Return the problem type URI, empty if not set
+func (e ErrHttp) Type() string {
+	if e.details == nil {
+		return ""
+	}
+	return e.details.problemType
+}
+
+// Return the problem title, empty if not set
+func (e ErrHttp) Title() string {
+	if e.details == nil {
+		return ""
+	}
+	return e.details.title
+}
+
+// Return the problem detail, empty if not set
+func (e ErrHttp) Detail() string {
+	if e.details == nil {
+		return ""
+	}
+	return e.details.detail
+}
+
+// Return the problem instance URI, empty if not set
+func (e ErrHttp) Instance() string {
+	if e.details == nil {
+		return ""
+	}
+	return e.details.instance
+}
+
+// Return the problem extension members, nil if not set
+func (e ErrHttp) Extensions() map[string]any {
+	if e.details == nil {
+		return nil
+	}
+	return e.details.extensions
+}
+
+// Return a copy of the error with the problem type URI set
+func (e ErrHttp) WithType(problemType string) ErrHttp {
+	return e.withDetails(func(d *errHttpDetails) { d.problemType = problemType })
+}
+
+// Return a copy of the error with the problem title set
+func (e ErrHttp) WithTitle(title string) ErrHttp {
+	return e.withDetails(func(d *errHttpDetails) { d.title = title })
+}
+
+// Return a copy of the error with the problem detail set
+func (e ErrHttp) WithDetail(detail string) ErrHttp {
+	return e.withDetails(func(d *errHttpDetails) { d.detail = detail })
+}
+
+// Return a copy of the error with the problem instance URI set
+func (e ErrHttp) WithInstance(instance string) ErrHttp {
+	return e.withDetails(func(d *errHttpDetails) { d.instance = instance })
+}
+
+// Return a copy of the error with a problem extension member set
+func (e ErrHttp) WithExtension(key string, value any) ErrHttp {
+	return e.withDetails(func(d *errHttpDetails) {
+		if d.extensions == nil {
+			d.extensions = map[string]any{}
+		}
+		d.extensions[key] = value
+	})
+}
+
+// Return a copy of the error with a cause attached, the cause is logged by WriteErr but never sent to the client
+func (e ErrHttp) Wrap(cause error) ErrHttp {
+	return e.withDetails(func(d *errHttpDetails) { d.cause = cause })
+}
+
+// Return the attached cause, nil if not set
+func (e ErrHttp) Unwrap() error {
+	if e.details == nil {
+		return nil
+	}
+	return e.details.cause
+}
+
+// Errors match when they have the same status code and message, regardless of any details or cause
+func (e ErrHttp) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrHttp:
+		return e.statusCode == t.statusCode && e.error == t.error
+	case *ErrHttp:
+		return t != nil && e.statusCode == t.statusCode && e.error == t.error
+	}
+	return false
+}
+
+// Copy the details so the shared package level errors are never mutated
+func (e ErrHttp) withDetails(update func(d *errHttpDetails)) ErrHttp {
+	details := errHttpDetails{}
+	if e.details != nil {
+		details = *e.details
+		details.extensions = maps.Clone(e.details.extensions)
+	}
+	update(&details)
+	e.details = &details
+	return e
+}
+
 func NewErrHttp(statusCode int, error string) ErrHttp {
-	return ErrHttp{statusCode, error}
+	return ErrHttp{statusCode: statusCode, error: error}
 }
 
 type ErrHttpValidation struct {
-	validationErrors map[string]string
+	validationErrors map[string][]string
+	messages         map[string][]string
 	ErrHttp
 }
 
+// Return the first failure for each field
 func (e ErrHttpValidation) ValidationErrors() map[string]string {
+	return firstPerField(e.validationErrors)
+}
+
+// Return every failure for each field
+func (e ErrHttpValidation) ValidationErrorList() map[string][]string {
 	return e.validationErrors
 }
 
+// Return the first message for each field
+func (e ErrHttpValidation) ValidationMessages() map[string]string {
+	return firstPerField(e.messages)
+}
+
+// Return every message for each field
+func (e ErrHttpValidation) ValidationMessageList() map[string][]string {
+	return e.messages
+}
+
+// Return a copy of the error with human readable messages for each field
+func (e ErrHttpValidation) WithMessages(messages map[string][]string) ErrHttpValidation {
+	e.messages = messages
+	return e
+}
+
+func firstPerField(values map[string][]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]string, len(values))
+	for field, list := range values {
+		if len(list) > 0 {
+			result[field] = list[0]
+		}
+	}
+	return result
+}
+
+// Return a copy of the error with the problem type URI set
+func (e ErrHttpValidation) WithType(problemType string) ErrHttpValidation {
+	e.ErrHttp = e.ErrHttp.WithType(problemType)
+	return e
+}
+
+// Return a copy of the error with the problem title set
+func (e ErrHttpValidation) WithTitle(title string) ErrHttpValidation {
+	e.ErrHttp = e.ErrHttp.WithTitle(title)
+	return e
+}
+
+// Return a copy of the error with the problem detail set
+func (e ErrHttpValidation) WithDetail(detail string) ErrHttpValidation {
+	e.ErrHttp = e.ErrHttp.WithDetail(detail)
+	return e
+}
+
+// Return a copy of the error with the problem instance URI set
+func (e ErrHttpValidation) WithInstance(instance string) ErrHttpValidation {
+	e.ErrHttp = e.ErrHttp.WithInstance(instance)
+	return e
+}
+
+// Return a copy of the error with a problem extension member set
+func (e ErrHttpValidation) WithExtension(key string, value any) ErrHttpValidation {
+	e.ErrHttp = e.ErrHttp.WithExtension(key, value)
+	return e
+}
+
+// Return a copy of the error with a cause attached, the cause is logged by WriteErr but never sent to the client
+func (e ErrHttpValidation) Wrap(cause error) ErrHttpValidation {
+	e.ErrHttp = e.ErrHttp.Wrap(cause)
+	return e
+}
+
+// Validation errors match any other validation error with the same status code and message
+func (e ErrHttpValidation) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrHttpValidation:
+		return e.ErrHttp.Is(t.ErrHttp)
+	case *ErrHttpValidation:
+		return t != nil && e.ErrHttp.Is(t.ErrHttp)
+	}
+	return e.ErrHttp.Is(target)
+}
+
 func NewErrHttpValidation(errors map[string]string) ErrHttpValidation {
-	return ErrHttpValidation{errors, ErrHttp{http.StatusBadRequest, "validation failed"}}
+	var validationErrors map[string][]string
+	if errors != nil {
+		validationErrors = make(map[string][]string, len(errors))
+		for field, code := range errors {
+			validationErrors[field] = []string{code}
+		}
+	}
+	return NewErrHttpValidationList(validationErrors)
+}
+
+// Create a validation error with any number of failures per field
+func NewErrHttpValidationList(errors map[string][]string) ErrHttpValidation {
+	return ErrHttpValidation{validationErrors: errors, ErrHttp: NewErrHttp(http.StatusBadRequest, "validation failed")}
 }
 
 // These are standard errors that should be returned at the repository level, its not meant to be
@@ -55,3 +295,13 @@ var (
 	ErrConflict     = NewErrHttp(http.StatusConflict, "conflict")
 	ErrInternal     = NewErrHttp(http.StatusInternalServerError, "internal server error")
 )
+
+// These are protocol level errors returned by the helpers in this package when a request can't be processed.
+var (
+	ErrNotAcceptable        = NewErrHttp(http.StatusNotAcceptable, "not acceptable")
+	ErrUnsupportedMediaType = NewErrHttp(http.StatusUnsupportedMediaType, "unsupported media type")
+	ErrRequestTooLarge      = NewErrHttp(http.StatusRequestEntityTooLarge, "request entity too large")
+	ErrGatewayTimeout       = NewErrHttp(http.StatusGatewayTimeout, "gateway timeout")
+	// Non standard status used when the client went away before a response was written
+	ErrClientClosedRequest = NewErrHttp(499, "client closed request")
+)
@@ -0,0 +1,87 @@
+package httpie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrHttpProblemDefaults(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "", ErrNotFound.Type())
+	assert.Equal(t, "", ErrNotFound.Title())
+	assert.Equal(t, "", ErrNotFound.Detail())
+	assert.Equal(t, "", ErrNotFound.Instance())
+	assert.Nil(t, ErrNotFound.Extensions())
+}
+
+func TestErrHttpProblemCopies(t *testing.T) {
+	t.Parallel()
+	base := NewErrHttp(http.StatusConflict, "conflict").WithExtension("a", 1)
+	err := base.WithDetail("already exists").WithExtension("b", 2)
+	assert.Equal(t, "already exists", err.Detail())
+	assert.Equal(t, map[string]any{"a": 1, "b": 2}, err.Extensions())
+	assert.Equal(t, "", base.Detail())
+	assert.Equal(t, map[string]any{"a": 1}, base.Extensions())
+	assert.Equal(t, "", ErrConflict.Detail())
+}
+
+func TestErrHttpComparable(t *testing.T) {
+	t.Parallel()
+	var err error = ErrNotFound
+	assert.True(t, err == ErrNotFound)
+	assert.False(t, err == ErrNotFound.WithDetail("missing"))
+}
+
+func TestErrHttpValidationProblem(t *testing.T) {
+	t.Parallel()
+	err := NewErrHttpValidation(map[string]string{"name": "required"}).WithTitle("Invalid input")
+	assert.Equal(t, "Invalid input", err.Title())
+	assert.Equal(t, map[string]string{"name": "required"}, err.ValidationErrors())
+	assert.Equal(t, http.StatusBadRequest, err.StatusCode())
+}
+
+func TestErrHttpIs(t *testing.T) {
+	t.Parallel()
+	err := fmt.Errorf("loading user: %w", ErrNotFound)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrConflict)
+	assert.ErrorIs(t, ErrNotFound.WithDetail("user 1"), ErrNotFound)
+	assert.ErrorIs(t, ErrNotFound, &ErrNotFound)
+	assert.ErrorIs(t, errors.Join(errors.New("other"), ErrForbidden), ErrForbidden)
+}
+
+func TestErrHttpWrap(t *testing.T) {
+	t.Parallel()
+	cause := errors.New("sql: no rows in result set")
+	err := ErrNotFound.Wrap(cause)
+	assert.ErrorIs(t, err, cause)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, cause, err.Unwrap())
+	assert.Equal(t, "not found", err.Error())
+	assert.Nil(t, ErrNotFound.Unwrap())
+}
+
+func TestErrHttpValidationIsAs(t *testing.T) {
+	t.Parallel()
+	cause := errors.New("cause")
+	validationErr := NewErrHttpValidation(map[string]string{"name": "required"}).Wrap(cause)
+	err := fmt.Errorf("creating user: %w", validationErr)
+	assert.ErrorIs(t, err, NewErrHttpValidation(nil))
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, ErrBadRequest)
+	var target ErrHttpValidation
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, map[string]string{"name": "required"}, target.ValidationErrors())
+}
+
+func TestErrHttpValidationList(t *testing.T) {
+	t.Parallel()
+	err := NewErrHttpValidationList(map[string][]string{"name": {"required", "min=3"}, "empty": {}})
+	assert.Equal(t, map[string]string{"name": "required"}, err.ValidationErrors())
+	assert.Equal(t, map[string][]string{"name": {"required", "min=3"}, "empty": {}}, err.ValidationErrorList())
+	assert.Nil(t, NewErrHttpValidation(nil).ValidationErrors())
+}
@@ -0,0 +1,57 @@
+package httpie
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// Handle adapts a typed function into an http.Handler
+// The request body is read with ReadJson and validated with the validator from GetValidator before calling the function,
+// failures have messages in the language from the Accept-Language header if the client sent one.
+// The result is written with WriteOkOrErrCtx, so errors are rendered with the request context.
+// Requests without a body skip decoding and use the zero value, for pointer types a pointer to the zero value.
+// The route pattern is recorded for the access log, see RouteMiddleware.
+func Handle[Req any, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordRoute(r)
+		var req Req
+		if r.Body != nil && r.Body != http.NoBody {
+			if err := ReadJson(r, &req); err != nil {
+				WriteErrCtx(r.Context(), w, err)
+				return
+			}
+		}
+		// A missing or null body leaves a pointer nil, its zero value is validated instead so required fields still fail
+		req = newIfNil(req)
+		if isStruct(req) {
+			if err := GetValidator(r.Context()).ValidateCtx(r.Context(), req, parseAcceptLanguage(r.Header.Get("Accept-Language"))...); err != nil {
+				WriteErrCtx(r.Context(), w, err)
+				return
+			}
+		}
+		resp, err := fn(r.Context(), req)
+		WriteOkOrErrCtx(r.Context(), w, resp, err)
+	})
+}
+
+// The validator only accepts structs (or pointers to them)
+func isStruct(v any) bool {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return false
+		}
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Struct
+}
+
+// Point a nil pointer at a new zero value
+func newIfNil[T any](v T) T {
+	value := reflect.ValueOf(&v).Elem()
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		value.Set(reflect.New(value.Type().Elem()))
+	}
+	return v
+}
@@ -0,0 +1,162 @@
+package httpie
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testHandleRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type testHandleResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func testGreet(ctx context.Context, req testHandleRequest) (testHandleResponse, error) {
+	if req.Name == "nobody" {
+		return testHandleResponse{}, ErrNotFound
+	}
+	return testHandleResponse{Greeting: "hello " + req.Name}, nil
+}
+
+func TestHandleOk(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"world"}`))
+	w := httptest.NewRecorder()
+	Handle(testGreet).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"greeting":"hello world"}`, w.Body.String())
+}
+
+func TestHandleErrRead(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name`))
+	w := httptest.NewRecorder()
+	Handle(testGreet).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"body":"syntax"},"detail":"invalid JSON: unexpected end of JSON input"}`, w.Body.String())
+}
+
+func TestHandleErrValidate(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	Handle(testGreet).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"name":"required"}}`, w.Body.String())
+}
+
+func TestHandleErrValidateLang(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{}`))
+	r.Header.Set("Accept-Language", "de;q=0.5, en")
+	w := httptest.NewRecorder()
+	Handle(testGreet).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"name":"required"},"messages":{"name":"name is a required field"}}`, w.Body.String())
+}
+
+// Not parallel, it changes the global DefaultErrOpts
+func TestHandleErrBag(t *testing.T) {
+	previous := DefaultErrOpts
+	DefaultErrOpts.Extensions = func(ctx context.Context, err IErrHttp) map[string]any {
+		if tenant, ok := GetBagValue(ctx, testTenantKey); ok {
+			return map[string]any{"tenant": tenant.ID}
+		}
+		return nil
+	}
+	defer func() { DefaultErrOpts = previous }()
+	tenants := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SetBagValue(r.Context(), testTenantKey, &testTenant{ID: "acme"})
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := RequestBagMiddleware()(tenants(Handle(testGreet)))
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"nobody"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"not found","tenant":"acme"}`, w.Body.String())
+
+	// Decode and validation failures are rendered with the request context too
+	r = httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"name":"required"},"tenant":"acme"}`, w.Body.String())
+}
+
+func TestHandleErrHandler(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"nobody"}`))
+	w := httptest.NewRecorder()
+	Handle(testGreet).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"not found"}`, w.Body.String())
+}
+
+func TestHandleNoBody(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	w := httptest.NewRecorder()
+	Handle(func(ctx context.Context, req struct{}) ([]string, error) {
+		return []string{"a"}, nil
+	}).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `["a"]`, w.Body.String())
+}
+
+func TestHandleNonStruct(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"a":"b"}`))
+	w := httptest.NewRecorder()
+	Handle(func(ctx context.Context, req map[string]string) (map[string]string, error) {
+		return req, nil
+	}).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"a":"b"}`, w.Body.String())
+}
+
+func TestHandlePointer(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	Handle(func(ctx context.Context, req *testHandleRequest) (*testHandleResponse, error) {
+		return &testHandleResponse{Greeting: req.Name}, nil
+	}).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlePointerNil(t *testing.T) {
+	t.Parallel()
+	for _, body := range []string{"null", ""} {
+		r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(body))
+		if body == "" {
+			r = httptest.NewRequest("POST", "http://example.com", nil)
+		}
+		w := httptest.NewRecorder()
+		called := false
+		Handle(func(ctx context.Context, req *testHandleRequest) (*testHandleResponse, error) {
+			called = true
+			return &testHandleResponse{Greeting: req.Name}, nil
+		}).ServeHTTP(w, r)
+		assert.False(t, called, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.JSONEq(t, `{"message":"validation failed","errors":{"name":"required"}}`, w.Body.String(), body)
+	}
+
+	// Without required fields the handler gets a pointer to the zero value, never nil
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader("null"))
+	w := httptest.NewRecorder()
+	Handle(func(ctx context.Context, req *struct{ Name string }) (string, error) {
+		assert.NotNil(t, req)
+		return "ok", nil
+	}).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
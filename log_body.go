@@ -0,0 +1,227 @@
+package httpie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Bytes of a body logged when LoggingOpts.BodyLogLimit is not set
+const DEFAULT_BODY_LOG_LIMIT = 4096
+
+// Media types whose bodies are logged when LoggingOpts.BodyContentTypes is not set
+// Only JSON and form bodies can have their fields redacted, so other types have to be opted into
+var DefaultBodyContentTypes = []string{
+	"application/json",
+	"application/*+json",
+	"application/x-www-form-urlencoded",
+}
+
+// Replaces the value of redacted fields in logged bodies
+const REDACTED = "[REDACTED]"
+
+// Captures up to limit bytes of a body
+type bodyCapture struct {
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func (c *bodyCapture) write(b []byte) {
+	room := c.limit - len(c.data)
+	if len(b) > room {
+		c.truncated = true
+		b = b[:max(room, 0)]
+	}
+	c.data = append(c.data, b...)
+}
+
+func (c *bodyCapture) reset() {
+	c.data = c.data[:0]
+	c.truncated = false
+}
+
+// Set up body capture for the request, the request body is read up to the limit and put back for the handler
+func (l *loggedRequest) captureBodies(r *http.Request) {
+	opts := l.opts
+	if !opts.LogRequestBody && !opts.LogResponseBody {
+		return
+	}
+	limit := opts.BodyLogLimit
+	if limit <= 0 {
+		limit = DEFAULT_BODY_LOG_LIMIT
+	}
+	if opts.LogResponseBody {
+		l.response = &bodyCapture{limit: limit}
+	}
+	if opts.LogRequestBody && r.Body != nil && r.Body != http.NoBody && l.loggable(r.Header.Get("Content-Type")) {
+		l.request = &bodyCapture{limit: limit}
+		// Read one byte past the limit to know if the body was truncated
+		data, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+		l.request.write(data)
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+	}
+}
+
+// Is the media type one of the body content types
+func (l *loggedRequest) loggable(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	patterns := l.opts.BodyContentTypes
+	if len(patterns) == 0 {
+		patterns = DefaultBodyContentTypes
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// The attributes for a captured body, nothing if it wasn't captured
+func (l *loggedRequest) bodyAttrs(key string, capture *bodyCapture, contentType string) []any {
+	if capture == nil || len(capture.data) == 0 {
+		return nil
+	}
+	attrs := []any{slog.String(key, l.bodies.redact(contentType, capture.data, capture.truncated))}
+	if capture.truncated {
+		attrs = append(attrs, slog.Bool(key+"_truncated", true))
+	}
+	return attrs
+}
+
+// Attributes for the captured request body
+func logRequestBodyAttrs(ctx context.Context, r *http.Request) []any {
+	l := getLoggedRequest(ctx)
+	if l == nil {
+		return nil
+	}
+	return l.bodyAttrs("request_body", l.request, r.Header.Get("Content-Type"))
+}
+
+// Attributes for the captured response body, only bodies with a body content type are logged
+// The request body is included when the request itself is not logged
+func logResponseBodyAttrs(ctx context.Context, r *http.Request, ww *WatchedResponseWriter) []any {
+	l := getLoggedRequest(ctx)
+	if l == nil {
+		return nil
+	}
+	var attrs []any
+	if !l.opts.LogRequest {
+		attrs = l.bodyAttrs("request_body", l.request, r.Header.Get("Content-Type"))
+	}
+	if l.response == nil {
+		return attrs
+	}
+	contentType := ww.Header().Get("Content-Type")
+	if !l.loggable(contentType) {
+		return attrs
+	}
+	return append(attrs, l.bodyAttrs("response_body", l.response, contentType)...)
+}
+
+// Replaces the values of fields in logged bodies, built once by LoggingMiddleware
+type bodyRedactor struct {
+	fields []string
+	// Used for truncated JSON bodies that can't be parsed
+	pattern *regexp.Regexp
+}
+
+// Create a redactor for the fields and DefaultRedactBodyFields
+func newBodyRedactor(fields []string) *bodyRedactor {
+	fields = append(slices.Clone(DefaultRedactBodyFields), fields...)
+	return &bodyRedactor{fields: fields, pattern: redactJsonPattern(fields)}
+}
+
+// Replace the values of the fields in a JSON or form body, fields match case insensitively at any depth
+func (b *bodyRedactor) redact(contentType string, data []byte, truncated bool) string {
+	fields := b.fields
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		return redactForm(string(data), fields)
+	}
+	if !truncated {
+		var document any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if decoder.Decode(&document) == nil {
+			redacted, err := json.Marshal(redactJson(document, fields))
+			if err == nil {
+				return string(redacted)
+			}
+		}
+	}
+	// A truncated document can't be parsed, so string and scalar values are replaced in place
+	return b.pattern.ReplaceAllString(string(data), `${1}"`+REDACTED+`"`)
+}
+
+// Replace the values of the fields in a form body, pairs are redacted one by one so a truncated or malformed body
+// keeps its order and a value cut off at the end is still replaced
+func redactForm(data string, fields []string) string {
+	pairs := strings.Split(data, "&")
+	for i, pair := range pairs {
+		raw, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(raw)
+		if err != nil {
+			key = raw
+		}
+		if matchesField(key, fields) {
+			pairs[i] = raw + "=" + url.QueryEscape(REDACTED)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func redactJson(value any, fields []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if matchesField(key, fields) {
+				v[key] = REDACTED
+			} else {
+				v[key] = redactJson(child, fields)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactJson(child, fields)
+		}
+	}
+	return value
+}
+
+func matchesField(key string, fields []string) bool {
+	for _, field := range fields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches "field": followed by a string or scalar value, the value may be cut off at the end of the body
+func redactJsonPattern(fields []string) *regexp.Regexp {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = regexp.QuoteMeta(field)
+	}
+	return regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*(?:"|\\?$)|[^\s,}\]]+)`)
+}
@@ -0,0 +1,198 @@
+package httpie
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bodyLog struct {
+	RequestBody           string `json:"request_body"`
+	RequestBodyTruncated  bool   `json:"request_body_truncated"`
+	ResponseBody          string `json:"response_body"`
+	ResponseBodyTruncated bool   `json:"response_body_truncated"`
+}
+
+func logBodies(t *testing.T, opts LoggingOpts, handler http.HandlerFunc, r *http.Request) (bodyLog, bodyLog) {
+	writer := bytes.NewBufferString("")
+	opts.LogRequest = true
+	opts.LogResponse = true
+	opts.OnRequest = DefaultLogRequest
+	opts.OnResponse = DefaultLogResponse
+	LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)), opts)(handler).ServeHTTP(httptest.NewRecorder(), r)
+	parts := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Len(t, parts, 2)
+	var reqLog, resLog bodyLog
+	assert.Nil(t, json.Unmarshal([]byte(parts[0]), &reqLog))
+	assert.Nil(t, json.Unmarshal([]byte(parts[1]), &resLog))
+	return reqLog, resLog
+}
+
+func TestLoggingBodiesResponseOnly(t *testing.T) {
+	t.Parallel()
+	writer := bytes.NewBufferString("")
+	middleware := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)), LoggingOpts{
+		LogResponse:    true,
+		OnResponse:     DefaultLogResponse,
+		LogRequestBody: true,
+	})
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"a":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	var resLog bodyLog
+	assert.Nil(t, json.Unmarshal(writer.Bytes(), &resLog))
+	assert.Equal(t, `{"a":1}`, resLog.RequestBody)
+	assert.Empty(t, resLog.ResponseBody)
+}
+
+func TestLoggingBodies(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(`{"user":"jane","Password":"hunter2","devices":[{"token":"abc","id":1}]}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	reqLog, resLog := logBodies(t, LoggingOpts{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		Redact:          RedactOpts{BodyFields: []string{"password", "token"}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		// The handler still gets the whole body
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Contains(t, string(body), "hunter2")
+		WriteOk(w, map[string]any{"token": "secret", "user": "jane"})
+	}, r)
+
+	assert.JSONEq(t, `{"user":"jane","Password":"[REDACTED]","devices":[{"token":"[REDACTED]","id":1}]}`, reqLog.RequestBody)
+	assert.False(t, reqLog.RequestBodyTruncated)
+	assert.Empty(t, reqLog.ResponseBody)
+	assert.Empty(t, resLog.RequestBody)
+	assert.JSONEq(t, `{"token":"[REDACTED]","user":"jane"}`, resLog.ResponseBody)
+}
+
+func TestLoggingBodiesTruncated(t *testing.T) {
+	t.Parallel()
+	body := `{"user":"jane","password":"hunter2","bio":"` + strings.Repeat("x", 100) + `"}`
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	reqLog, resLog := logBodies(t, LoggingOpts{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		BodyLogLimit:    40,
+		Redact:          RedactOpts{BodyFields: []string{"password"}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		assert.Equal(t, body, string(data))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`"` + strings.Repeat("y", 50) + `"`))
+	}, r)
+
+	assert.Equal(t, `{"user":"jane","password":"[REDACTED]","bio`, reqLog.RequestBody)
+	assert.True(t, reqLog.RequestBodyTruncated)
+	assert.Equal(t, `"`+strings.Repeat("y", 39), resLog.ResponseBody)
+	assert.True(t, resLog.ResponseBodyTruncated)
+}
+
+func TestLoggingBodiesTruncatedForm(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "/login", strings.NewReader("password=hunter2hunter2&user=someone"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	reqLog, _ := logBodies(t, LoggingOpts{
+		LogRequestBody: true,
+		BodyLogLimit:   20,
+	}, func(w http.ResponseWriter, r *http.Request) {}, r)
+	assert.Equal(t, "password=%5BREDACTED%5D", reqLog.RequestBody)
+	assert.True(t, reqLog.RequestBodyTruncated)
+}
+
+func TestLoggingBodiesRequestUntouched(t *testing.T) {
+	t.Parallel()
+	body := io.NopCloser(strings.NewReader(`{"a":1}`))
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Body = body
+	r.Header.Set("Content-Type", "application/json")
+	reqLog, _ := logBodies(t, LoggingOpts{LogRequestBody: true}, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"a":1}`, string(data))
+	}, r)
+	assert.Equal(t, `{"a":1}`, reqLog.RequestBody)
+	// The caller's request keeps its own body
+	assert.Equal(t, body, r.Body)
+}
+
+func TestLoggingBodiesContentTypes(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "/upload", strings.NewReader("binary"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	reqLog, resLog := logBodies(t, LoggingOpts{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}, r)
+	assert.Empty(t, reqLog.RequestBody)
+	assert.Empty(t, resLog.ResponseBody)
+
+	// Fields can't be redacted from text and XML bodies so they are not logged by default
+	r = httptest.NewRequest("POST", "/notes", strings.NewReader("password: hunter2"))
+	r.Header.Set("Content-Type", "text/plain")
+	reqLog, resLog = logBodies(t, LoggingOpts{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		Redact:          RedactOpts{BodyFields: []string{"password"}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte("<password>hunter2</password>"))
+	}, r)
+	assert.Empty(t, reqLog.RequestBody)
+	assert.Empty(t, resLog.ResponseBody)
+
+	r = httptest.NewRequest("POST", "/upload", strings.NewReader("binary"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	reqLog, resLog = logBodies(t, LoggingOpts{
+		LogRequestBody:   true,
+		LogResponseBody:  true,
+		BodyContentTypes: []string{"application/octet-stream", "image/*"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}, r)
+	assert.Equal(t, "binary", reqLog.RequestBody)
+	assert.Equal(t, "png", resLog.ResponseBody)
+}
+
+func TestLoggingBodiesReset(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "/report", nil)
+	_, resLog := logBodies(t, LoggingOpts{LogResponseBody: true}, func(w http.ResponseWriter, r *http.Request) {
+		ww := NewWatchedResponseWriter(w)
+		defer ww.Apply()
+		ww.Header().Set("Content-Type", "text/csv")
+		ww.Write([]byte("a,b"))
+		ww.Reset()
+		WriteErr(ww, ErrInternal)
+	}, r)
+	assert.JSONEq(t, `{"message":"internal server error"}`, resLog.ResponseBody)
+}
+
+func TestRedactBody(t *testing.T) {
+	t.Parallel()
+	redactor := newBodyRedactor([]string{"password", "api_key"})
+	assert.Equal(t, "user=jane&api%5Fkey=%5BREDACTED%5D", redactor.redact("application/x-www-form-urlencoded", []byte("user=jane&api%5Fkey=abc"), false))
+	assert.Equal(t, "user=jane&password=%5BREDACTED%5D&remember", redactor.redact("application/x-www-form-urlencoded; charset=utf-8", []byte("user=jane&password=hunter2&remember"), false))
+	assert.Equal(t, `{"password":"[REDACTED]"}`, redactor.redact("application/json", []byte(`{"password":{"old":"a","new":"b"}}`), false))
+	assert.JSONEq(t, `{"password":"[REDACTED]","n":1.50}`, redactor.redact("application/json", []byte(`{"password":1234,"n":1.50}`), false))
+	assert.Equal(t, `{"a":1,"password": "[REDACTED]"`, redactor.redact("application/json", []byte(`{"a":1,"password": "hunt`), true))
+	assert.Equal(t, `{"API_KEY":"[REDACTED]","b":"x\"y"}`, redactor.redact("application/json", []byte(`{"API_KEY":"a\"b","b":"x\"y"}`), true))
+	// DefaultRedactBodyFields are always redacted
+	assert.Equal(t, `{"Token":"[REDACTED]","user":"x"}`, newBodyRedactor(nil).redact("application/json", []byte(`{"user":"x","Token":"abc"}`), false))
+	assert.Equal(t, `{"user":"x"}`, newBodyRedactor(nil).redact("application/json", []byte(`{"user":"x"}`), false))
+}
@@ -0,0 +1,133 @@
+package httpie
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// Request metadata and attributes for the records of a request, kept in the RequestBag so attributes added
+// by downstream middleware are seen by the access log
+var (
+	// The request http.ServeMux sets the route pattern on
+	logRequestKey = NewBagKey[*http.Request]("log request")
+	logUserKey    = NewBagKey[string]("log user")
+	logAttrsKey   = NewBagKey[[]slog.Attr]("log attributes")
+)
+
+// Add attributes to every record logged with ctx through a ContextHandler and to the response log of LoggingMiddleware
+// With a RequestBag ctx is returned as is, otherwise the returned context carries the attributes
+func AddLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	ctx = WithRequestBag(ctx)
+	UpdateBagValue(ctx, logAttrsKey, func(existing []slog.Attr) []slog.Attr {
+		return append(existing, attrs...)
+	})
+	return ctx
+}
+
+// Set the user of the request, logged as user like the attributes of AddLogAttrs
+func SetLogUser(ctx context.Context, user string) context.Context {
+	ctx = WithRequestBag(ctx)
+	SetBagValue(ctx, logUserKey, user)
+	return ctx
+}
+
+// The user and added attributes
+func logUserAttrs(ctx context.Context) []slog.Attr {
+	attrs, _ := GetBagValue(ctx, logAttrsKey)
+	// Clipped so prepending the user or appending by the caller copies instead of sharing the bag's array
+	attrs = slices.Clip(attrs)
+	if user, _ := GetBagValue(ctx, logUserKey); user != "" {
+		attrs = append([]slog.Attr{slog.String("user", user)}, attrs...)
+	}
+	return attrs
+}
+
+// RouteMiddleware records the request http.ServeMux routes so the access log and ContextHandler have its route
+// LoggingMiddleware only sees the route when the mux gets the request it passed on, wrap the mux with it when
+// middleware in between replace the request (r.WithContext). Handlers created with Handle record it themselves.
+//
+//	handler := httpie.LoggingMiddleware(logger)(auth(httpie.RouteMiddleware()(mux)))
+func RouteMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// http.ServeMux sets the route pattern on the request it is given
+			SetBagValue(r.Context(), logRequestKey, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Record a request that has been routed, requests without a route pattern are ignored
+func recordRoute(r *http.Request) {
+	if r.Pattern != "" {
+		SetBagValue(r.Context(), logRequestKey, r)
+	}
+}
+
+// The route pattern set by http.ServeMux, empty until the request is routed
+func logRoute(ctx context.Context) string {
+	r, _ := GetBagValue(ctx, logRequestKey)
+	if r == nil {
+		return ""
+	}
+	return r.Pattern
+}
+
+// ContextHandler adds the request ID, trace, method, route, user and attributes from AddLogAttrs to records logged with a request context
+//
+//	slog.SetDefault(slog.New(httpie.NewContextHandler(slog.NewJSONHandler(os.Stdout, nil))))
+//	slog.InfoContext(r.Context(), "user.created")
+type ContextHandler struct {
+	handler slog.Handler
+}
+
+// Create a ContextHandler that passes the enriched records to handler
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{handler: handler}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := contextLogAttrs(ctx)
+	if len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{handler: h.handler.WithGroup(name)}
+}
+
+// Return the wrapped handler
+func (h *ContextHandler) Unwrap() slog.Handler {
+	return h.handler
+}
+
+// The request attributes available from a context
+func contextLogAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if id := GetRequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if span := GetSpan(ctx); span != nil {
+		attrs = append(attrs, slog.String("trace_id", span.TraceID.String()), slog.String("span_id", span.SpanID.String()))
+	}
+	if r, _ := GetBagValue(ctx, logRequestKey); r != nil {
+		attrs = append(attrs, slog.String("method", r.Method))
+		if r.Pattern != "" {
+			attrs = append(attrs, slog.String("route", r.Pattern))
+		}
+	}
+	return append(attrs, logUserAttrs(ctx)...)
+}
@@ -0,0 +1,66 @@
+package httpie
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"time"
+)
+
+// Level of a response log by status class, server errors (5xx) are Error, client errors (4xx) are Warn and everything else is Info
+func DefaultStatusLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// The level of a response log, slow responses are logged at Warn or above
+func (l *loggedRequest) responseLevel(status int, duration time.Duration) slog.Level {
+	statusLevel := DefaultStatusLevel
+	if l != nil && l.opts.StatusLevel != nil {
+		statusLevel = l.opts.StatusLevel
+	}
+	level := statusLevel(status)
+	if l.slow(duration) {
+		level = max(level, slog.LevelWarn)
+	}
+	return level
+}
+
+// Did the response take longer than the SlowThreshold
+func (l *loggedRequest) slow(duration time.Duration) bool {
+	return l != nil && l.opts.SlowThreshold > 0 && duration > l.opts.SlowThreshold
+}
+
+// Should the path be left out of the logs, patterns match the request path and not the route
+func (o LoggingOpts) skipped(r *http.Request) bool {
+	for _, pattern := range o.SkipPaths {
+		if ok, _ := path.Match(pattern, r.URL.Path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Is the request sampled, the lowest rate of the patterns matching the request path is used
+// The route isn't known yet since the request log is written before the request reaches the mux
+// Paths that match no pattern are always sampled
+func (o LoggingOpts) sampled(r *http.Request) bool {
+	rate := 1.0
+	for pattern, patternRate := range o.SampleRates {
+		if ok, _ := path.Match(pattern, r.URL.Path); ok {
+			rate = min(rate, patternRate)
+		}
+	}
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// Should the response be logged, errors and slow responses are always logged even when the request was not sampled
+func (l *loggedRequest) logResponse(status int, duration time.Duration) bool {
+	return l.sampled || status >= 400 || l.slow(duration)
+}
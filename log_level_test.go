@@ -0,0 +1,172 @@
+package httpie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type levelLog struct {
+	Level  string
+	Msg    string
+	Status int
+	Slow   bool
+}
+
+func serveLevelLogs(t *testing.T, opts LoggingOpts, handler http.HandlerFunc, paths ...string) []levelLog {
+	writer := bytes.NewBufferString("")
+	opts.OnRequest = DefaultLogRequest
+	opts.OnResponse = DefaultLogResponse
+	middleware := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)), opts)(handler)
+	for _, p := range paths {
+		middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+	}
+	var logs []levelLog
+	output := strings.TrimSpace(writer.String())
+	if output == "" {
+		return logs
+	}
+	for _, part := range strings.Split(output, "\n") {
+		var log levelLog
+		assert.Nil(t, json.Unmarshal([]byte(part), &log))
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/missing":
+		w.WriteHeader(404)
+	case "/broken":
+		w.WriteHeader(503)
+	default:
+		w.WriteHeader(200)
+	}
+}
+
+func TestDefaultStatusLevel(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, slog.LevelInfo, DefaultStatusLevel(200))
+	assert.Equal(t, slog.LevelInfo, DefaultStatusLevel(302))
+	assert.Equal(t, slog.LevelWarn, DefaultStatusLevel(404))
+	assert.Equal(t, slog.LevelError, DefaultStatusLevel(500))
+}
+
+func TestLoggingStatusLevels(t *testing.T) {
+	t.Parallel()
+	logs := serveLevelLogs(t, LoggingOpts{LogResponse: true}, statusHandler, "/", "/missing", "/broken")
+	assert.Len(t, logs, 3)
+	assert.Equal(t, []string{"INFO", "WARN", "ERROR"}, []string{logs[0].Level, logs[1].Level, logs[2].Level})
+
+	logs = serveLevelLogs(t, LoggingOpts{
+		LogResponse: true,
+		StatusLevel: func(status int) slog.Level {
+			if status == 404 {
+				return slog.LevelDebug
+			}
+			return DefaultStatusLevel(status)
+		},
+	}, statusHandler, "/missing", "/broken")
+	// Debug records are dropped by the handler
+	assert.Len(t, logs, 1)
+	assert.Equal(t, "ERROR", logs[0].Level)
+}
+
+func TestLoggingSlowThreshold(t *testing.T) {
+	t.Parallel()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		statusHandler(w, r)
+	}
+	logs := serveLevelLogs(t, LoggingOpts{LogResponse: true, SlowThreshold: 10 * time.Millisecond}, handler, "/", "/slow")
+	assert.Len(t, logs, 2)
+	assert.Equal(t, levelLog{Level: "INFO", Msg: "http.response", Status: 200}, logs[0])
+	assert.Equal(t, levelLog{Level: "WARN", Msg: "http.response", Status: 200, Slow: true}, logs[1])
+}
+
+func TestLoggingSampling(t *testing.T) {
+	t.Parallel()
+	opts := LoggingOpts{
+		LogRequest:  true,
+		LogResponse: true,
+		SampleRates: map[string]float64{"/healthz": 0, "/jobs/*": 0.5},
+	}
+	paths := make([]string, 0, 200)
+	for range 100 {
+		paths = append(paths, "/healthz", "/jobs/1")
+	}
+	logs := serveLevelLogs(t, opts, statusHandler, paths...)
+	// Roughly half of the jobs are logged with a request and response line, none of the health checks
+	assert.Greater(t, len(logs), 40)
+	assert.Less(t, len(logs), 160)
+	assert.Equal(t, 0, len(logs)%2)
+
+	// Errors are logged even when the request was not sampled
+	logs = serveLevelLogs(t, LoggingOpts{LogRequest: true, LogResponse: true, SampleRates: map[string]float64{"/*": 0}}, statusHandler, "/", "/broken")
+	assert.Len(t, logs, 1)
+	assert.Equal(t, levelLog{Level: "ERROR", Msg: "http.response", Status: 503}, logs[0])
+}
+
+func TestLoggingSkipPaths(t *testing.T) {
+	t.Parallel()
+	logs := serveLevelLogs(t, LoggingOpts{
+		LogRequest:  true,
+		LogResponse: true,
+		SkipPaths:   []string{"/healthz", "/jobs/*/status"},
+	}, statusHandler, "/healthz", "/jobs/1/status", "/jobs/1")
+	assert.Len(t, logs, 2)
+	assert.Equal(t, "http.request", logs[0].Msg)
+	assert.Equal(t, "http.response", logs[1].Msg)
+}
+
+func TestLoggingSkipPathsBag(t *testing.T) {
+	t.Parallel()
+	var bagged, logged bool
+	var user []slog.Attr
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// Downstream middleware still get the bag and log context on skipped paths
+		bagged = SetBagValue(r.Context(), testQueriesKey, 1)
+		SetLogUser(r.Context(), "jane")
+		user = logUserAttrs(r.Context())
+		logged = getLoggedRequest(r.Context()) != nil
+	}
+	logs := serveLevelLogs(t, LoggingOpts{LogRequest: true, LogResponse: true, SkipPaths: []string{"/healthz"}}, handler, "/healthz")
+	assert.Empty(t, logs)
+	assert.True(t, bagged)
+	assert.True(t, logged)
+	assert.Equal(t, []slog.Attr{slog.String("user", "jane")}, user)
+}
+
+func TestLoggingDurationMeasuredOnce(t *testing.T) {
+	t.Parallel()
+	var durations []any
+	middleware := LoggingMiddleware(slog.New(slog.NewJSONHandler(io.Discard, nil)), LoggingOpts{
+		LogResponse: true,
+		OnResponse: func(ctx context.Context, slogger *slog.Logger, r *http.Request, ww *WatchedResponseWriter, start time.Time) {
+			for range 2 {
+				for _, attr := range DefaultLogResponseAttr(ctx, r, ww, start) {
+					if attr := attr.(slog.Attr); attr.Key == "duration" || attr.Key == "time" {
+						durations = append(durations, attr.Value.Any())
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+		},
+	})
+	middleware(http.HandlerFunc(statusHandler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Len(t, durations, 4)
+	// The end of the request is taken once when the handler returns, not every time it is logged
+	assert.Equal(t, durations[:2], durations[2:])
+}
@@ -0,0 +1,199 @@
+package httpie
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	passwordvalidator "github.com/wagslane/go-password-validator"
+)
+
+// PasswordPolicy describes the rules a password must satisfy for the securepassword validation
+type PasswordPolicy struct {
+	// Minimum entropy in bits as calculated by go-password-validator (0 disables the check)
+	MinEntropy float64
+	// Minimum number of characters (0 disables the check)
+	MinLength int
+	// Maximum number of characters (0 disables the check)
+	MaxLength int
+	// Require at least one lowercase letter
+	RequireLower bool
+	// Require at least one uppercase letter
+	RequireUpper bool
+	// Require at least one digit
+	RequireDigit bool
+	// Require at least one symbol or punctuation character
+	RequireSymbol bool
+	// Words that must not appear in the password (case insensitive)
+	BannedWords []string
+	// Sibling struct fields (by Go field name) whose values must not appear in the password, for example Email
+	ContextFields []string
+}
+
+// Failure codes returned by PasswordPolicy.Check
+const (
+	PasswordEntropy   = "entropy"
+	PasswordMinLength = "min_length"
+	PasswordMaxLength = "max_length"
+	PasswordLower     = "lower"
+	PasswordUpper     = "upper"
+	PasswordDigit     = "digit"
+	PasswordSymbol    = "symbol"
+	PasswordBanned    = "banned"
+	PasswordContext   = "context"
+)
+
+// Check a password against the policy and return a failure code for every rule it breaks
+// Codes with a parameter are formatted as code=param (min_length=12), contextValues must not appear in the password
+func (p PasswordPolicy) Check(password string, contextValues ...string) []string {
+	var failures []string
+	if p.MinEntropy > 0 && passwordvalidator.GetEntropy(password) < p.MinEntropy {
+		failures = append(failures, PasswordEntropy+"="+strconv.FormatFloat(p.MinEntropy, 'f', -1, 64))
+	}
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		failures = append(failures, PasswordMinLength+"="+strconv.Itoa(p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		failures = append(failures, PasswordMaxLength+"="+strconv.Itoa(p.MaxLength))
+	}
+	if p.RequireLower && !strings.ContainsFunc(password, unicode.IsLower) {
+		failures = append(failures, PasswordLower)
+	}
+	if p.RequireUpper && !strings.ContainsFunc(password, unicode.IsUpper) {
+		failures = append(failures, PasswordUpper)
+	}
+	if p.RequireDigit && !strings.ContainsFunc(password, unicode.IsDigit) {
+		failures = append(failures, PasswordDigit)
+	}
+	if p.RequireSymbol && !strings.ContainsFunc(password, isPasswordSymbol) {
+		failures = append(failures, PasswordSymbol)
+	}
+	lower := strings.ToLower(password)
+	for _, word := range p.BannedWords {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			failures = append(failures, PasswordBanned)
+			break
+		}
+	}
+	for _, value := range contextValues {
+		if containsContextValue(lower, strings.ToLower(value)) {
+			failures = append(failures, PasswordContext)
+			break
+		}
+	}
+	return failures
+}
+
+func isPasswordSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// Email addresses also match on their local part so "jane.doe@example.com" rejects "jane.doe2024"
+func containsContextValue(password string, value string) bool {
+	if value == "" {
+		return false
+	}
+	if strings.Contains(password, value) {
+		return true
+	}
+	if local, _, ok := strings.Cut(value, "@"); ok && len(local) >= 3 {
+		return strings.Contains(password, local)
+	}
+	return false
+}
+
+// Register a named password policy on DefaultValidator, used with the securepassword tag parameter (securepassword=strong)
+// Registering the empty name replaces the default policy used by a bare securepassword tag
+func RegisterPasswordPolicy(name string, policy PasswordPolicy) {
+	DefaultValidator.RegisterPasswordPolicy(name, policy)
+}
+
+// Register a named password policy, used with the securepassword tag parameter (securepassword=strong)
+func (v *HttpValidator) RegisterPasswordPolicy(name string, policy PasswordPolicy) {
+	v.passwordPoliciesMu.Lock()
+	defer v.passwordPoliciesMu.Unlock()
+	v.passwordPolicies[name] = policy
+}
+
+// Find a registered password policy
+func (v *HttpValidator) getPasswordPolicy(name string) (PasswordPolicy, error) {
+	v.passwordPoliciesMu.RLock()
+	defer v.passwordPoliciesMu.RUnlock()
+	policy, ok := v.passwordPolicies[name]
+	if !ok {
+		return policy, fmt.Errorf("unknown password policy %q", name)
+	}
+	return policy, nil
+}
+
+// Check the field against the policy named by the tag parameter
+func (v *HttpValidator) checkPasswordField(fl validator.FieldLevel) ([]string, error) {
+	policy, err := v.getPasswordPolicy(fl.Param())
+	if err != nil {
+		return nil, err
+	}
+	var contextValues []string
+	parent := reflect.Indirect(fl.Parent())
+	if parent.Kind() == reflect.Struct {
+		for _, name := range policy.ContextFields {
+			field := parent.FieldByName(name)
+			if field.IsValid() && field.Kind() == reflect.String {
+				contextValues = append(contextValues, field.String())
+			}
+		}
+	}
+	return policy.Check(fl.Field().String(), contextValues...), nil
+}
+
+type passwordFailure struct {
+	password string
+	param    string
+	codes    []string
+}
+
+// Collects the rule failures while validating, the validator can only report a single boolean per tag
+type passwordFailures struct {
+	mu       sync.Mutex
+	failures []passwordFailure
+}
+
+// Remove and return the rule failures recorded for a failed securepassword field
+func (p *passwordFailures) take(password string, param string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, failure := range p.failures {
+		if failure.password == password && failure.param == param {
+			p.failures = append(p.failures[:i], p.failures[i+1:]...)
+			return failure.codes
+		}
+	}
+	return nil
+}
+
+var passwordFailuresCtxKey ctxKey = 1
+
+// The securepassword validation used by Validate, records the rule failures so they can be reported
+// An unknown policy fails the field and is returned as an internal error like the errors of context-aware rules
+func (v *HttpValidator) securePasswordValidatorCtx(ctx context.Context, fl validator.FieldLevel) bool {
+	codes, err := v.checkPasswordField(fl)
+	if err != nil {
+		recordValidationRuleErr(ctx, validationRuleErr(fl, err))
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	if failures, ok := ctx.Value(passwordFailuresCtxKey).(*passwordFailures); ok {
+		failures.mu.Lock()
+		failures.failures = append(failures.failures, passwordFailure{fl.Field().String(), fl.Param(), codes})
+		failures.mu.Unlock()
+	}
+	return false
+}
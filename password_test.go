@@ -0,0 +1,121 @@
+package httpie
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPasswordValidator = NewValidator(ValidatorOpts{
+	PasswordPolicies: map[string]PasswordPolicy{
+		"teststrong": {
+			MinLength:     12,
+			MaxLength:     20,
+			RequireLower:  true,
+			RequireUpper:  true,
+			RequireDigit:  true,
+			RequireSymbol: true,
+			BannedWords:   []string{"acme"},
+			ContextFields: []string{"Email", "Username"},
+		},
+	},
+})
+
+type testPasswordStruct struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password" validate:"securepassword=teststrong"`
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	t.Parallel()
+	policy := PasswordPolicy{
+		MinEntropy:    80,
+		MinLength:     8,
+		MaxLength:     10,
+		RequireLower:  true,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		BannedWords:   []string{"", "Password"},
+	}
+	assert.Equal(t, []string{"entropy=80", "max_length=10", "upper", "digit", "symbol", "banned"}, policy.Check("mypassword"+"xy"))
+	assert.Equal(t, []string{"entropy=80", "min_length=8", "lower", "symbol"}, policy.Check("AB12"))
+	assert.Empty(t, PasswordPolicy{}.Check(""))
+	assert.Empty(t, PasswordPolicy{MinLength: 3}.Check("äöü"))
+}
+
+func TestPasswordPolicyContext(t *testing.T) {
+	t.Parallel()
+	policy := PasswordPolicy{}
+	assert.Equal(t, []string{"context"}, policy.Check("Jane.Doe!2024", "", "jane.doe@example.com"))
+	assert.Equal(t, []string{"context"}, policy.Check("xxjane.doe@example.comxx", "jane.doe@example.com"))
+	assert.Empty(t, policy.Check("ab-secret-2024", "ab@example.com"))
+	assert.Empty(t, policy.Check("correct-horse", ""))
+}
+
+func TestValidatorPasswordPolicy(t *testing.T) {
+	t.Parallel()
+	err := testPasswordValidator.Validate(testPasswordStruct{Email: "jane@example.com", Username: "janedoe", Password: "acme-janedoe"})
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string][]string{
+		"password": {
+			"securepassword=teststrong",
+			"securepassword.upper",
+			"securepassword.digit",
+			"securepassword.banned",
+			"securepassword.context",
+		},
+	}, err.(IErrHttpValidationList).ValidationErrorList())
+
+	err = testPasswordValidator.Validate(testPasswordStruct{Email: "jane@example.com", Password: "Correct-Horse-9"})
+	assert.Nil(t, err)
+}
+
+func TestValidatorPasswordPolicySlice(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Password string `json:"password" validate:"securepassword=teststrong"`
+	}
+	data := struct {
+		Items []item `json:"items" validate:"dive"`
+	}{Items: []item{{"short"}, {"Correct-Horse-9"}, {"short"}}}
+	err := testPasswordValidator.Validate(data)
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string][]string{
+		"items[0].password": {"securepassword=teststrong", "securepassword.min_length=12", "securepassword.upper", "securepassword.digit", "securepassword.symbol"},
+		"items[2].password": {"securepassword=teststrong", "securepassword.min_length=12", "securepassword.upper", "securepassword.digit", "securepassword.symbol"},
+	}, err.(IErrHttpValidationList).ValidationErrorList())
+}
+
+func TestValidatorPasswordPolicyDefault(t *testing.T) {
+	t.Parallel()
+	err := Validate(testValidateStruct{Name: "test", Password: "test", Age: 1})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"securepassword", "securepassword.entropy=60"}, err.(IErrHttpValidationList).ValidationErrorList()["Password"])
+}
+
+func TestValidatorPasswordPolicyMessages(t *testing.T) {
+	t.Parallel()
+	err := testPasswordValidator.ValidateLang(testPasswordStruct{Password: "Short-1"}, "en")
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string][]string{
+		"password": {"password is not a secure password", "password must be at least 12 characters long"},
+	}, err.(IErrHttpValidationMessages).ValidationMessageList())
+}
+
+func TestValidatorPasswordPolicyUnknown(t *testing.T) {
+	t.Parallel()
+	data := struct {
+		Password string `validate:"securepassword=missing"`
+	}{Password: "x"}
+	err := ValidateCtx(context.Background(), data)
+	assert.EqualError(t, err, `httpie: validation securepassword on Password: unknown password policy "missing"`)
+	assert.Equal(t, 500, MapErr(err).StatusCode())
+
+	// Without a context the error is logged and the field fails
+	validationErr := Validate(data)
+	assert.NotNil(t, validationErr)
+	assert.Equal(t, map[string]string{"Password": "securepassword=missing"}, validationErr.ValidationErrors())
+}
@@ -1,20 +1,292 @@
 package httpie
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
+// ReadOpts are the options for ReadJson
+type ReadOpts struct {
+	// Maximum number of bytes to read from the body, larger bodies return ErrRequestTooLarge (0 is unlimited)
+	MaxBytes int64
+	// Reject documents containing fields that don't exist in the data object
+	DisallowUnknownFields bool
+	// Reject objects that contain the same key more than once
+	DisallowDuplicateKeys bool
+	// Require a JSON Content-Type header, otherwise return ErrUnsupportedMediaType
+	RequireContentType bool
+}
+
+// Default read options, change these to configure ReadJson globally
+var DefaultReadOpts = ReadOpts{
+	MaxBytes:              0,
+	DisallowUnknownFields: false,
+	DisallowDuplicateKeys: false,
+	RequireContentType:    false,
+}
+
 // Read a JSON document from the request body and unmarshal it into the provided data object
-func ReadJson[T any](r *http.Request, data *T) error {
-	body, err := io.ReadAll(r.Body)
+// Documents with data after the first JSON value are rejected
+// Decoding failures are returned as an ErrHttpValidation keyed by the JSON field path
+func ReadJson[T any](r *http.Request, data *T, opts ...ReadOpts) error {
+	var opt ReadOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	} else {
+		opt = DefaultReadOpts
+	}
+
+	if opt.RequireContentType && !isJsonContentType(r.Header.Get("Content-Type")) {
+		return ErrUnsupportedMediaType
+	}
+	reader := r.Body
+	if opt.MaxBytes > 0 {
+		reader = http.MaxBytesReader(nil, r.Body, opt.MaxBytes)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return ErrRequestTooLarge.Wrap(err)
+		}
+		return ErrBadRequest.Wrap(err)
+	}
+	if opt.DisallowDuplicateKeys {
+		if err := checkDuplicateKeys(body); err != nil {
+			return decodeErr(err)
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if opt.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	err = decoder.Decode(data)
+	if err != nil {
+		return decodeErr(withJsonIndexes(err, body))
+	}
+	// Anything after the first value is always rejected, the same as json.Unmarshal
+	if _, err := decoder.Token(); err != io.EOF {
+		return decodeErr(errTrailingData)
+	}
+	return nil
+}
+
+var errTrailingData = errors.New("json: unexpected data after top-level value")
+
+// The validation error key used for problems with the document as a whole
+const bodyField = "body"
+
+// Convert a JSON decoding error into an ErrHttpValidation keyed by the JSON field path
+// The detail explains what was expected and where, anything unrecognized becomes ErrBadRequest
+func decodeErr(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var duplicateErr *errDuplicateKey
+	switch {
+	case errors.As(err, &typeErr):
+		field := jsonFieldPath(typeErr.Field)
+		if field == "" {
+			field = bodyField
+		}
+		expected := jsonTypeName(typeErr.Type)
+		return NewErrHttpValidation(map[string]string{field: "type=" + expected}).
+			WithDetail(fmt.Sprintf("expected %s for %s but got %s at offset %d", expected, field, typeErr.Value, typeErr.Offset)).
+			Wrap(err)
+	case errors.As(err, &syntaxErr):
+		return NewErrHttpValidation(map[string]string{bodyField: "syntax"}).
+			WithDetail(fmt.Sprintf("invalid JSON at offset %d: %s", syntaxErr.Offset, strings.TrimPrefix(syntaxErr.Error(), "json: "))).
+			Wrap(err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return NewErrHttpValidation(map[string]string{bodyField: "syntax"}).
+			WithDetail("invalid JSON: unexpected end of JSON input").
+			Wrap(err)
+	case errors.Is(err, io.EOF):
+		return NewErrHttpValidation(map[string]string{bodyField: "required"}).
+			WithDetail("a JSON document is required").
+			Wrap(err)
+	case errors.As(err, &duplicateErr):
+		return NewErrHttpValidation(map[string]string{duplicateErr.path: "duplicate"}).
+			WithDetail(fmt.Sprintf("duplicate key %s", duplicateErr.path)).
+			Wrap(err)
+	case errors.Is(err, errTrailingData):
+		return NewErrHttpValidation(map[string]string{bodyField: "trailing"}).
+			WithDetail("unexpected data after the JSON document").
+			Wrap(err)
+	}
+	// The json package has no error type for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, _ = strconv.Unquote(field)
+		return NewErrHttpValidation(map[string]string{field: "unknown"}).
+			WithDetail(fmt.Sprintf("unknown field %s", field)).
+			Wrap(err)
+	}
+	return ErrBadRequest.Wrap(err)
+}
+
+// Newer versions of encoding/json include array indexes as path segments (items.0.price)
+// these are rewritten to match the validator namespace format (items[0].price)
+func jsonFieldPath(field string) string {
+	if field == "" {
+		return ""
+	}
+	var path strings.Builder
+	for i, segment := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(segment); err == nil && i > 0 {
+			path.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(segment)
+	}
+	return path.String()
+}
+
+// The expected type reported in the validation error code
+func jsonTypeName(valueType reflect.Type) string {
+	if valueType == nil {
+		return "unknown"
+	}
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+	switch valueType.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Interface:
+		return "any"
+	}
+	return valueType.Kind().String()
+}
+
+// Matches application/json and structured syntax suffixes like application/problem+json
+func isJsonContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// Returned when an object contains the same key more than once
+type errDuplicateKey struct {
+	path string
+}
+
+func (e *errDuplicateKey) Error() string {
+	return fmt.Sprintf("json: duplicate key %q", e.path)
+}
+
+// Walk the document and fail on the first object with a repeated key
+func checkDuplicateKeys(body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	return walkJson(decoder, "", nil)
+}
+
+// Encoding/json before Go 1.24 leaves array indexes out of type errors (items.price)
+// The body is walked to find the value at the error offset so the path always has them (items[1].price)
+func withJsonIndexes(err error, body []byte) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return err
+	}
+	want := stripJsonIndexes(jsonFieldPath(typeErr.Field))
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	walkJson(decoder, "", func(path string, start int64, end int64) {
+		if start < typeErr.Offset && typeErr.Offset <= end && stripJsonIndexes(path) == want {
+			typeErr.Field = path
+		}
+	})
+	return err
+}
+
+// Remove the array indexes from a path (items[1].price becomes items.price)
+func stripJsonIndexes(path string) string {
+	var stripped strings.Builder
+	inIndex := false
+	for _, c := range path {
+		switch {
+		case c == '[':
+			inIndex = true
+		case c == ']':
+			inIndex = false
+		case !inIndex:
+			stripped.WriteRune(c)
+		}
+	}
+	return stripped.String()
+}
+
+// Walk a JSON value, visit is called with the path and the offsets around each value if set
+func walkJson(decoder *json.Decoder, path string, visit func(path string, start int64, end int64)) error {
+	start := decoder.InputOffset()
+	if visit != nil {
+		defer func() {
+			visit(path, start, decoder.InputOffset())
+		}()
+	}
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		keys := map[string]struct{}{}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key := token.(string)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if _, ok := keys[key]; ok {
+				return &errDuplicateKey{keyPath}
+			}
+			keys[key] = struct{}{}
+			if err := walkJson(decoder, keyPath, visit); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			if err := walkJson(decoder, path+"["+strconv.Itoa(i)+"]", visit); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	}
+	return nil
+}
+
+// Read the request body using the decoder registered for its Content-Type and unmarshal it into the provided data object
+// Returns ErrUnsupportedMediaType if no decoder matches, a missing Content-Type uses the default decoder
+func ReadBody[T any](r *http.Request, data *T) error {
+	decoder, err := DefaultCodecs.Decoder(r.Header.Get("Content-Type"))
 	if err != nil {
-		return ErrBadRequest
+		return err
 	}
-	err = json.Unmarshal(body, data)
+	err = decoder.Decode(r.Body, data)
 	if err != nil {
-		return ErrBadRequest
+		return decodeErr(err)
 	}
 	return nil
 }
@@ -1,11 +1,14 @@
 package httpie
 
 import (
+	"encoding/json"
 	"errors"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -38,3 +41,247 @@ func TestReadJsonErrIO(t *testing.T) {
 	err := ReadJson(r, &data)
 	assert.Error(t, err)
 }
+
+func TestReadBodyJson(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	var data testStruct
+	err := ReadBody(r, &data)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", data.Name)
+}
+
+func TestReadBodyXml(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`<testStruct><Name>hello</Name></testStruct>`))
+	r.Header.Set("Content-Type", "application/xml")
+	var data testStruct
+	err := ReadBody(r, &data)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", data.Name)
+}
+
+func TestReadBodyDefault(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello"}`))
+	var data testStruct
+	err := ReadBody(r, &data)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", data.Name)
+}
+
+func TestReadBodyUnsupported(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`name=hello`))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var data testStruct
+	err := ReadBody(r, &data)
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+}
+
+func TestReadBodyErrSyntax(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name`))
+	var data testStruct
+	err := ReadBody(r, &data)
+	validationErr, ok := err.(IErrHttpValidation)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"body": "syntax"}, validationErr.ValidationErrors())
+}
+
+func TestReadJsonErrTrailingData(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello"} {"name":"world"}`))
+	var data testStruct
+	err := ReadJson(r, &data)
+	assertDecodeErr(t, err, map[string]string{"body": "trailing"}, "unexpected data after the JSON document")
+}
+
+func TestReadJsonTrailingWhitespace(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader("{\"name\":\"hello\"}\n\n"))
+	var data testStruct
+	err := ReadJson(r, &data)
+	assert.NoError(t, err)
+}
+
+func TestReadJsonMaxBytes(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello"}`))
+	var data testStruct
+	err := ReadJson(r, &data, ReadOpts{MaxBytes: 5})
+	assert.ErrorIs(t, err, ErrRequestTooLarge)
+	var maxBytesErr *http.MaxBytesError
+	assert.ErrorAs(t, err, &maxBytesErr)
+
+	r = httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello"}`))
+	err = ReadJson(r, &data, ReadOpts{MaxBytes: 16})
+	assert.NoError(t, err)
+}
+
+func TestReadJsonDisallowUnknownFields(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello","other":1}`))
+	var data testStruct
+	err := ReadJson(r, &data, ReadOpts{DisallowUnknownFields: true})
+	assertDecodeErr(t, err, map[string]string{"other": "unknown"}, "unknown field other")
+
+	r = httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello","other":1}`))
+	err = ReadJson(r, &data)
+	assert.NoError(t, err)
+}
+
+func TestReadJsonDisallowDuplicateKeys(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		`{"name":"a","name":"b"}`:                     "name",
+		`{"items":[{"a":1},{"a":1,"a":2}]}`:           "items[1].a",
+		`{"nested":{"deep":{"x":1,"x":2}},"y":[1,2]}`: "nested.deep.x",
+	}
+	for body, path := range tests {
+		r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(body))
+		var data map[string]any
+		err := ReadJson(r, &data, ReadOpts{DisallowDuplicateKeys: true})
+		assertDecodeErr(t, err, map[string]string{path: "duplicate"}, "duplicate key "+path)
+		var duplicateErr *errDuplicateKey
+		assert.ErrorAs(t, err, &duplicateErr, body)
+		assert.Equal(t, path, duplicateErr.path, body)
+	}
+
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"a":{"x":1},"b":{"x":1},"c":[{"x":1},{"x":2}]}`))
+	var data map[string]any
+	err := ReadJson(r, &data, ReadOpts{DisallowDuplicateKeys: true})
+	assert.NoError(t, err)
+}
+
+func TestReadJsonRequireContentType(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"application/merge-patch+json":    true,
+		"":                                false,
+		"text/plain":                      false,
+		"text/json+xml":                   false,
+	}
+	for contentType, ok := range tests {
+		r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"name":"hello"}`))
+		r.Header.Set("Content-Type", contentType)
+		var data testStruct
+		err := ReadJson(r, &data, ReadOpts{RequireContentType: true})
+		if ok {
+			assert.NoError(t, err, contentType)
+		} else {
+			assert.Equal(t, ErrUnsupportedMediaType, err, contentType)
+		}
+	}
+}
+
+func assertDecodeErr(t *testing.T, err error, validations map[string]string, detail string) {
+	t.Helper()
+	var validationErr ErrHttpValidation
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, validations, validationErr.ValidationErrors())
+		assert.Equal(t, detail, validationErr.Detail())
+	}
+}
+
+type testDecodeStruct struct {
+	Name  string `json:"name"`
+	Items []struct {
+		Price float64 `json:"price"`
+	} `json:"items"`
+	Address struct {
+		Zip int
+	} `json:"address"`
+}
+
+func TestReadJsonErrType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		body        string
+		validations map[string]string
+		detail      string
+	}{
+		{`{"name":1}`, map[string]string{"name": "type=string"}, "expected string for name but got number at offset 9"},
+		{`{"address":{"Zip":true}}`, map[string]string{"address.Zip": "type=int"}, "expected int for address.Zip but got bool at offset 22"},
+		{`{"items":{}}`, map[string]string{"items": "type=array"}, "expected array for items but got object at offset 10"},
+		{`[]`, map[string]string{"body": "type=object"}, "expected object for body but got array at offset 1"},
+		{`{"name":"a",}`, map[string]string{"body": "syntax"}, "invalid JSON at offset 13: invalid character '}' looking for beginning of object key string"},
+		{``, map[string]string{"body": "required"}, "a JSON document is required"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(test.body))
+		var data testDecodeStruct
+		err := ReadJson(r, &data)
+		assertDecodeErr(t, err, test.validations, test.detail)
+	}
+}
+
+func TestReadJsonErrTypeArray(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"items":[{"price":1},{"price":"x"}]}`))
+	var data testDecodeStruct
+	err := ReadJson(r, &data)
+	var validationErr ErrHttpValidation
+	assert.ErrorAs(t, err, &validationErr)
+	// The index is found from the body on versions of encoding/json that don't report it
+	assert.Equal(t, map[string]string{"items[1].price": "type=float64"}, validationErr.ValidationErrors())
+
+	r = httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"items":[{"price":1}, {"price":2}, {"price":{}}]}`))
+	validationErr = ErrHttpValidation{}
+	assert.ErrorAs(t, ReadJson(r, &data), &validationErr)
+	assert.Equal(t, map[string]string{"items[2].price": "type=float64"}, validationErr.ValidationErrors())
+}
+
+func TestWithJsonIndexes(t *testing.T) {
+	t.Parallel()
+	body := []byte(`{"items":[{"price":1},{"price":"x"}],"price":"y"}`)
+	var data struct {
+		Items []struct{ Price float64 }
+		Price float64
+	}
+	err := json.Unmarshal(body, &data)
+	var typeErr *json.UnmarshalTypeError
+	assert.ErrorAs(t, err, &typeErr)
+	// Report the field the way encoding/json before Go 1.24 does
+	typeErr.Field = "items.price"
+	assert.Same(t, err, withJsonIndexes(err, body))
+	assert.Equal(t, "items[1].price", typeErr.Field)
+}
+
+func TestStripJsonIndexes(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "items.price", stripJsonIndexes("items[10].price"))
+	assert.Equal(t, "matrix", stripJsonIndexes("matrix[1][2]"))
+}
+
+// decodeErr recognizes unknown fields by the wording of encoding/json, this fails if it changes
+func TestUnknownFieldWording(t *testing.T) {
+	t.Parallel()
+	decoder := json.NewDecoder(strings.NewReader(`{"other":1}`))
+	decoder.DisallowUnknownFields()
+	var data struct{}
+	err := decoder.Decode(&data)
+	assert.Equal(t, `json: unknown field "other"`, err.Error())
+}
+
+func TestJsonFieldPath(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "", jsonFieldPath(""))
+	assert.Equal(t, "name", jsonFieldPath("name"))
+	assert.Equal(t, "items[0].price", jsonFieldPath("items.0.price"))
+	assert.Equal(t, "matrix[1][2]", jsonFieldPath("matrix.1.2"))
+	assert.Equal(t, "0", jsonFieldPath("0"))
+}
+
+func TestReadJsonErrOther(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", strings.NewReader(`{"time":"yesterday"}`))
+	var data struct {
+		Time time.Time `json:"time"`
+	}
+	err := ReadJson(r, &data)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
@@ -0,0 +1,153 @@
+package httpie
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Query keys whose values are always replaced in logs, matched case insensitively
+var DefaultRedactQueryKeys = []string{
+	"access_token",
+	"api_key",
+	"apikey",
+	"code",
+	"key",
+	"password",
+	"refresh_token",
+	"secret",
+	"sig",
+	"signature",
+	"token",
+}
+
+// Headers whose values are always replaced in logs
+var DefaultRedactHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// JSON and form fields whose values are always replaced in logged bodies, matched case insensitively at any depth
+var DefaultRedactBodyFields = []string{
+	"access_token",
+	"api_key",
+	"apikey",
+	"client_secret",
+	"password",
+	"refresh_token",
+	"secret",
+	"token",
+}
+
+// RedactOpts controls which parts of a request and response are replaced with REDACTED before they are logged
+// A key in both a deny and an allow list is redacted
+type RedactOpts struct {
+	// Query keys whose values are replaced, in addition to DefaultRedactQueryKeys
+	QueryKeys []string
+	// When set only these query keys are logged as is, the values of all others are replaced
+	AllowQueryKeys []string
+	// Headers whose values are replaced, in addition to DefaultRedactHeaders
+	Headers []string
+	// When set only these headers are logged as is, the values of all others are replaced
+	AllowHeaders []string
+	// Path segments matching any of these are replaced (^[0-9a-f]{32}$ for /reset/{token})
+	PathSegments []*regexp.Regexp
+	// JSON and form fields whose values are replaced in logged bodies, in addition to DefaultRedactBodyFields
+	BodyFields []string
+}
+
+// Redact the values of denied query keys, the values are copied so the request is untouched
+func (o RedactOpts) Query(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for key, value := range values {
+		if o.redactQueryKey(key) {
+			redacted[key] = []string{REDACTED}
+		} else {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+func (o RedactOpts) redactQueryKey(key string) bool {
+	if matchesField(key, DefaultRedactQueryKeys) || matchesField(key, o.QueryKeys) {
+		return true
+	}
+	return len(o.AllowQueryKeys) > 0 && !matchesField(key, o.AllowQueryKeys)
+}
+
+// Redact the path segments matching PathSegments (/reset/abc123 becomes /reset/[REDACTED])
+func (o RedactOpts) Path(p string) string {
+	if len(o.PathSegments) == 0 {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		for _, pattern := range o.PathSegments {
+			if pattern.MatchString(segment) {
+				segments[i] = REDACTED
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Redact the path and query of a URL such as the Referer header, URLs that don't parse are redacted entirely
+func (o RedactOpts) URL(rawURL string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return REDACTED
+	}
+	u.Path = o.Path(u.Path)
+	u.RawPath = ""
+	if u.RawQuery != "" {
+		u.RawQuery = o.Query(u.Query()).Encode()
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// Redact the values of a header if it is denied
+func (o RedactOpts) Header(name string, values []string) []string {
+	if o.redactHeader(name) {
+		return []string{REDACTED}
+	}
+	return values
+}
+
+func (o RedactOpts) redactHeader(name string) bool {
+	if matchesField(name, DefaultRedactHeaders) || matchesField(name, o.Headers) {
+		return true
+	}
+	return len(o.AllowHeaders) > 0 && !matchesField(name, o.AllowHeaders)
+}
+
+// A group of the named headers that are set, values are redacted and joined like net/http combines them
+func (o RedactOpts) headerAttrs(key string, header http.Header, names []string) []any {
+	var attrs []any
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		attrs = append(attrs, slog.String(name, strings.Join(o.Header(name, values), ", ")))
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return []any{slog.Group(key, attrs...)}
+}
@@ -0,0 +1,115 @@
+package httpie
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactQuery(t *testing.T) {
+	t.Parallel()
+	values := url.Values{"page": {"2"}, "Token": {"abc"}, "reset": {"xyz"}}
+	redacted := RedactOpts{QueryKeys: []string{"reset"}}.Query(values)
+	assert.Equal(t, url.Values{"page": {"2"}, "Token": {REDACTED}, "reset": {REDACTED}}, redacted)
+	// The request is untouched
+	assert.Equal(t, []string{"abc"}, values["Token"])
+
+	redacted = RedactOpts{AllowQueryKeys: []string{"page", "token"}}.Query(values)
+	assert.Equal(t, url.Values{"page": {"2"}, "Token": {REDACTED}, "reset": {REDACTED}}, redacted)
+}
+
+func TestRedactPath(t *testing.T) {
+	t.Parallel()
+	opts := RedactOpts{PathSegments: []*regexp.Regexp{regexp.MustCompile(`^[0-9a-f]{32}$`)}}
+	assert.Equal(t, "/reset/[REDACTED]/confirm", opts.Path("/reset/0123456789abcdef0123456789abcdef/confirm"))
+	assert.Equal(t, "/users/42/", opts.Path("/users/42/"))
+	assert.Equal(t, "/users/42", RedactOpts{}.Path("/users/42"))
+}
+
+func TestRedactURL(t *testing.T) {
+	t.Parallel()
+	opts := RedactOpts{PathSegments: []*regexp.Regexp{regexp.MustCompile(`^tok_`)}}
+	assert.Equal(t, "https://example.com/invite/%5BREDACTED%5D?api_key=%5BREDACTED%5D&page=1", opts.URL("https://example.com/invite/tok_abc?page=1&api_key=secret#access_token=abc"))
+	assert.Equal(t, "", opts.URL(""))
+	assert.Equal(t, REDACTED, opts.URL("http://[::1"))
+}
+
+func TestRedactHeader(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{REDACTED}, RedactOpts{}.Header("authorization", []string{"Bearer abc"}))
+	assert.Equal(t, []string{REDACTED}, RedactOpts{Headers: []string{"X-Session"}}.Header("X-Session", []string{"abc"}))
+	assert.Equal(t, []string{"abc"}, RedactOpts{}.Header("X-Session", []string{"abc"}))
+	assert.Equal(t, []string{REDACTED}, RedactOpts{AllowHeaders: []string{"Accept"}}.Header("X-Session", []string{"abc"}))
+}
+
+type redactedLog struct {
+	Msg             string
+	Path            string
+	Query           map[string][]string
+	Referer         string
+	RequestHeaders  map[string]string `json:"request_headers"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+}
+
+func TestLoggingRedaction(t *testing.T) {
+	t.Parallel()
+	writer := bytes.NewBufferString("")
+	middleware := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)), LoggingOpts{
+		LogRequest:  true,
+		LogResponse: true,
+		OnRequest:   DefaultLogRequest,
+		OnResponse:  DefaultLogResponse,
+		Redact: RedactOpts{
+			QueryKeys:    []string{"invite"},
+			PathSegments: []*regexp.Regexp{regexp.MustCompile(`^[0-9a-f]{32}$`)},
+		},
+		LogRequestHeaders:  []string{"accept", "Authorization", "X-Missing"},
+		LogResponseHeaders: []string{"Content-Type", "Set-Cookie"},
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler sees the real values
+		assert.Equal(t, "secret", r.URL.Query().Get("token"))
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		WriteOk(w, map[string]string{})
+	})
+	r := httptest.NewRequest("GET", "/reset/0123456789abcdef0123456789abcdef?token=secret&invite=abc&page=1", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Authorization", "Bearer abc")
+	r.Header.Set("Referer", "https://example.com/login?token=secret")
+	middleware(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+	parts := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Len(t, parts, 2)
+	var reqLog, resLog redactedLog
+	assert.Nil(t, json.Unmarshal([]byte(parts[0]), &reqLog))
+	assert.Nil(t, json.Unmarshal([]byte(parts[1]), &resLog))
+	for _, log := range []redactedLog{reqLog, resLog} {
+		assert.Equal(t, "/reset/[REDACTED]", log.Path)
+		assert.Equal(t, map[string][]string{"token": {REDACTED}, "invite": {REDACTED}, "page": {"1"}}, log.Query)
+		assert.Equal(t, "https://example.com/login?token=%5BREDACTED%5D", log.Referer)
+	}
+	assert.Equal(t, map[string]string{"Accept": "application/json", "Authorization": REDACTED}, reqLog.RequestHeaders)
+	assert.Nil(t, reqLog.ResponseHeaders)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "Set-Cookie": REDACTED}, resLog.ResponseHeaders)
+	assert.Nil(t, resLog.RequestHeaders)
+}
+
+func TestDefaultLogRequestAttrRedacts(t *testing.T) {
+	t.Parallel()
+	// The default deny lists apply without the middleware
+	r := httptest.NewRequest("GET", "/?api_key=abc", nil)
+	writer := bytes.NewBufferString("")
+	DefaultLogRequest(r.Context(), slog.New(slog.NewJSONHandler(writer, nil)), r, time.Now())
+	var log redactedLog
+	assert.Nil(t, json.Unmarshal(writer.Bytes(), &log))
+	assert.Equal(t, map[string][]string{"api_key": {REDACTED}}, log.Query)
+}
@@ -0,0 +1,101 @@
+package httpie
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// RequestBag holds values set while a request is handled, shared by pointer so outer middleware can read
+// what downstream middleware set after next.ServeHTTP returns. It is safe to use from several goroutines.
+type RequestBag struct {
+	mu     sync.RWMutex
+	values map[any]any
+}
+
+// BagKey is a typed key for a RequestBag, each key created by NewBagKey is distinct even with the same name
+type BagKey[T any] struct {
+	name string
+}
+
+// Create a key for values of type T, the name is only used to describe the key
+//
+//	var TenantKey = httpie.NewBagKey[Tenant]("tenant")
+func NewBagKey[T any](name string) *BagKey[T] {
+	return &BagKey[T]{name: name}
+}
+
+func (k *BagKey[T]) String() string {
+	return k.name
+}
+
+var requestBagCtxKey ctxKey = 8
+
+// RequestBagMiddleware installs a RequestBag for requests handled by next, put it outside of the middleware that reads the bag
+// An existing bag is reused so only the outermost middleware installs one
+func RequestBagMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if GetRequestBag(ctx) == nil {
+				r = r.WithContext(WithRequestBag(ctx))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Get a context that carries a RequestBag, ctx is returned as is if it already has one
+func WithRequestBag(ctx context.Context) context.Context {
+	if GetRequestBag(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestBagCtxKey, &RequestBag{values: map[any]any{}})
+}
+
+// Get the RequestBag from the context, nil if there is none
+func GetRequestBag(ctx context.Context) *RequestBag {
+	bag, _ := ctx.Value(requestBagCtxKey).(*RequestBag)
+	return bag
+}
+
+// Set a value in the RequestBag of the context, returns false if the context has no bag
+func SetBagValue[T any](ctx context.Context, key *BagKey[T], value T) bool {
+	bag := GetRequestBag(ctx)
+	if bag == nil {
+		return false
+	}
+	bag.mu.Lock()
+	bag.values[key] = value
+	bag.mu.Unlock()
+	return true
+}
+
+// Get a value from the RequestBag of the context, returns false if it was not set or the context has no bag
+func GetBagValue[T any](ctx context.Context, key *BagKey[T]) (T, bool) {
+	bag := GetRequestBag(ctx)
+	if bag == nil {
+		var zero T
+		return zero, false
+	}
+	bag.mu.RLock()
+	value, ok := bag.values[key].(T)
+	bag.mu.RUnlock()
+	return value, ok
+}
+
+// Replace a value in the RequestBag of the context with the result of fn, which gets the zero value if it was not set
+// The bag is locked while fn runs so concurrent updates are not lost, returns false if the context has no bag
+//
+//	httpie.UpdateBagValue(ctx, QueriesKey, func(n int) int { return n + 1 })
+func UpdateBagValue[T any](ctx context.Context, key *BagKey[T], fn func(T) T) bool {
+	bag := GetRequestBag(ctx)
+	if bag == nil {
+		return false
+	}
+	bag.mu.Lock()
+	defer bag.mu.Unlock()
+	value, _ := bag.values[key].(T)
+	bag.values[key] = fn(value)
+	return true
+}
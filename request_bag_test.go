@@ -0,0 +1,117 @@
+package httpie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTenant struct {
+	ID   string
+	Plan string
+}
+
+var testTenantKey = NewBagKey[*testTenant]("tenant")
+var testQueriesKey = NewBagKey[int]("queries")
+
+func TestRequestBag(t *testing.T) {
+	t.Parallel()
+	var tenant *testTenant
+	var queries int
+	// Read the bag after the handler has returned, like a metrics middleware would
+	metrics := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			tenant, _ = GetBagValue(r.Context(), testTenantKey)
+			queries, _ = GetBagValue(r.Context(), testQueriesKey)
+		})
+	}
+	resolveTenant := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, SetBagValue(r.Context(), testTenantKey, &testTenant{ID: "acme", Plan: "pro"}))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), testTestCtxKey, "other")))
+		})
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				UpdateBagValue(r.Context(), testQueriesKey, func(n int) int { return n + 1 })
+			}()
+		}
+		wg.Wait()
+	})
+	RequestBagMiddleware()(metrics(resolveTenant(handler))).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, &testTenant{ID: "acme", Plan: "pro"}, tenant)
+	assert.Equal(t, 10, queries)
+}
+
+func TestRequestBagNested(t *testing.T) {
+	t.Parallel()
+	var outer, inner *RequestBag
+	handler := RequestBagMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = GetRequestBag(r.Context())
+	}))
+	RequestBagMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outer = GetRequestBag(r.Context())
+		handler.ServeHTTP(w, r)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.NotNil(t, outer)
+	assert.Same(t, outer, inner)
+}
+
+func TestRequestBagMissing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	assert.Nil(t, GetRequestBag(ctx))
+	assert.False(t, SetBagValue(ctx, testQueriesKey, 1))
+	assert.False(t, UpdateBagValue(ctx, testQueriesKey, func(n int) int { return n + 1 }))
+	value, ok := GetBagValue(ctx, testQueriesKey)
+	assert.False(t, ok)
+	assert.Equal(t, 0, value)
+
+	// Keys with the same name and type are still distinct
+	ctx = WithRequestBag(ctx)
+	SetBagValue(ctx, testQueriesKey, 1)
+	_, ok = GetBagValue(ctx, NewBagKey[int]("queries"))
+	assert.False(t, ok)
+	assert.Equal(t, "queries", testQueriesKey.String())
+}
+
+func TestLoggingRequestBag(t *testing.T) {
+	t.Parallel()
+	writer := bytes.NewBufferString("")
+	middleware := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)), LoggingOpts{
+		LogResponse: true,
+		OnResponse: func(ctx context.Context, slogger *slog.Logger, r *http.Request, ww *WatchedResponseWriter, start time.Time) {
+			attrs := DefaultLogResponseAttr(ctx, r, ww, start)
+			if tenant, ok := GetBagValue(ctx, testTenantKey); ok {
+				attrs = append(attrs, slog.String("plan", tenant.Plan))
+			}
+			slogger.Info("http.response", attrs...)
+		},
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetBagValue(r.Context(), testTenantKey, &testTenant{ID: "acme", Plan: "pro"})
+		WriteErr(w, ErrNotFound)
+	})
+	middleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	var log struct {
+		Plan   string
+		Status int
+	}
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimSpace(writer.String())), &log))
+	assert.Equal(t, "pro", log.Plan)
+	assert.Equal(t, 404, log.Status)
+}
@@ -0,0 +1,151 @@
+package httpie
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	mathrand "math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Header used for request IDs when RequestIDOpts.Header is not set
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// Longest incoming request ID accepted when RequestIDOpts.MaxLength is not set
+const MAX_REQUEST_ID_LENGTH = 128
+
+// RequestIDOpts are the options for the RequestIDMiddleware
+type RequestIDOpts struct {
+	// Header the request ID is read from and echoed on, defaults to REQUEST_ID_HEADER
+	Header string
+	// Longest incoming request ID to accept, defaults to MAX_REQUEST_ID_LENGTH
+	MaxLength int
+	// Should incoming request IDs be ignored? Use this when clients are not trusted to pick them
+	IgnoreIncoming bool
+	// Generates a request ID when the request doesn't have a valid one, defaults to NewRequestID
+	Generate func() string
+}
+
+// Default request ID options
+var DefaultRequestIDOpts = RequestIDOpts{
+	Header:    REQUEST_ID_HEADER,
+	MaxLength: MAX_REQUEST_ID_LENGTH,
+	Generate:  NewRequestID,
+}
+
+var requestIDCtxKey ctxKey = 5
+
+// RequestIDMiddleware gives every request an ID, available with GetRequestID and echoed on the response
+// An incoming request ID is kept if it is valid so a request can be followed across services
+func RequestIDMiddleware(opts ...RequestIDOpts) func(http.Handler) http.Handler {
+	opt := DefaultRequestIDOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Header == "" {
+		opt.Header = REQUEST_ID_HEADER
+	}
+	opt.Header = http.CanonicalHeaderKey(opt.Header)
+	if opt.MaxLength <= 0 {
+		opt.MaxLength = MAX_REQUEST_ID_LENGTH
+	}
+	if opt.Generate == nil {
+		opt.Generate = NewRequestID
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(opt.Header)
+			if opt.IgnoreIncoming || !validRequestID(id, opt.MaxLength) {
+				id = opt.Generate()
+			}
+			// The writer remembers the header so WriteErr can find the request ID without the request
+			ww := NewWatchedResponseWriter(w, WatchOpts{Mode: WatchPassThrough})
+			defer ww.Apply()
+			ww.requestID = id
+			ww.requestIDHeader = opt.Header
+			ww.Header().Set(opt.Header, id)
+			next.ServeHTTP(ww, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// Only printable characters that are safe to log and put in headers are allowed (letters, digits and -_.:/+=@)
+func validRequestID(id string, maxLength int) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '/' || c == '+' || c == '=' || c == '@':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Get a context that carries the request ID for GetRequestID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey, id)
+}
+
+// Get the request ID from the context, empty if there is none
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey).(string)
+	return id
+}
+
+// The request ID echoed on a response by RequestIDMiddleware, empty if there is none
+// The ID is found on the WatchedResponseWriter the middleware passed on, following Unwrap
+func responseRequestID(w http.ResponseWriter) string {
+	for next := w; next != nil; {
+		if ww, ok := next.(*WatchedResponseWriter); ok && ww.requestIDHeader != "" {
+			return ww.requestID
+		}
+		unwrapper, ok := next.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return ""
+		}
+		next = unwrapper.Unwrap()
+	}
+	return ""
+}
+
+// Fill b with random bytes, math/rand is used if crypto/rand fails since IDs only need to be unique
+func randomBytes(b []byte) {
+	if _, err := readRandom(b); err == nil {
+		return
+	}
+	for i := range b {
+		b[i] = byte(mathrand.Uint32())
+	}
+}
+
+// Replaced by tests to make crypto/rand fail
+var readRandom = rand.Read
+
+// Create a random UUIDv7, these sort by the time they were created
+func NewRequestID() string {
+	var uuid [16]byte
+	randomBytes(uuid[:])
+	// The first 48 bits are the unix time in milliseconds
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(uuid[:6], ms[2:])
+	uuid[6] = uuid[6]&0x0f | 0x70
+	uuid[8] = uuid[8]&0x3f | 0x80
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf[:])
+}
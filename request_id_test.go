@@ -0,0 +1,200 @@
+package httpie
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var uuidV7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewRequestID(t *testing.T) {
+	t.Parallel()
+	first := NewRequestID()
+	second := NewRequestID()
+	assert.Regexp(t, uuidV7Pattern, first)
+	assert.NotEqual(t, first, second)
+	// The timestamp prefix keeps them in order
+	assert.LessOrEqual(t, first[:13], second[:13])
+}
+
+// Runs before the parallel tests so replacing readRandom doesn't affect them
+func TestNewRequestIDRandomFailure(t *testing.T) {
+	defer func(read func([]byte) (int, error)) { readRandom = read }(readRandom)
+	readRandom = func(b []byte) (int, error) {
+		return 0, errors.New("entropy unavailable")
+	}
+	first := NewRequestID()
+	assert.Regexp(t, uuidV7Pattern, first)
+	assert.NotEqual(t, first, NewRequestID())
+}
+
+func serveRequestID(opts []RequestIDOpts, incoming string) (*httptest.ResponseRecorder, string) {
+	var seen string
+	handler := RequestIDMiddleware(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(r.Context())
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	if incoming != "" {
+		r.Header.Set("X-Request-ID", incoming)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, seen
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+	w, id := serveRequestID(nil, "")
+	assert.Regexp(t, uuidV7Pattern, id)
+	assert.Equal(t, id, w.Header().Get("X-Request-ID"))
+
+	w, id = serveRequestID(nil, "abc-123:retry.1")
+	assert.Equal(t, "abc-123:retry.1", id)
+	assert.Equal(t, id, w.Header().Get("X-Request-ID"))
+
+	// Invalid IDs are replaced
+	for _, incoming := range []string{"has space", "line\nbreak", "<script>", strings.Repeat("a", 129)} {
+		_, id = serveRequestID(nil, incoming)
+		assert.Regexp(t, uuidV7Pattern, id, incoming)
+	}
+}
+
+func TestRequestIDMiddlewareOpts(t *testing.T) {
+	t.Parallel()
+	opts := []RequestIDOpts{{
+		Header:    "x-correlation-id",
+		MaxLength: 4,
+		Generate:  func() string { return "generated" },
+	}}
+	handler := RequestIDMiddleware(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetRequestID(r.Context())))
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Correlation-ID", "abcd")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "abcd", w.Body.String())
+	assert.Equal(t, "abcd", w.Header().Get("X-Correlation-ID"))
+
+	r.Header.Set("X-Correlation-ID", "abcde")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "generated", w.Body.String())
+
+	w, id := serveRequestID([]RequestIDOpts{{IgnoreIncoming: true}}, "abc")
+	assert.Regexp(t, uuidV7Pattern, id)
+	assert.Equal(t, id, w.Header().Get("X-Request-ID"))
+}
+
+func TestRequestIDErrBody(t *testing.T) {
+	t.Parallel()
+	handler := RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteErr(w, ErrNotFound)
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.JSONEq(t, `{"message":"not found","request_id":"req-1"}`, w.Body.String())
+
+	handler = RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteErr(w, ErrNotFound, ErrOpts{ProblemDetails: true})
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","request_id":"req-1"}`, w.Body.String())
+
+	// Only the header of this middleware is used, not one configured by another server in the process
+	other := RequestIDMiddleware(RequestIDOpts{Header: "X-Correlation-ID"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	other.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	handler = RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Correlation-ID", "upstream")
+		// Wrapped by another middleware the request ID is still found
+		WriteErr(&testWrappedWriter{w}, ErrNotFound)
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.JSONEq(t, `{"message":"not found","request_id":"req-1"}`, w.Body.String())
+
+	// Without the middleware there is no request ID
+	w = httptest.NewRecorder()
+	WriteErr(w, ErrNotFound)
+	assert.JSONEq(t, `{"message":"not found"}`, w.Body.String())
+}
+
+func TestRequestIDTransactionErr(t *testing.T) {
+	t.Parallel()
+	m := new(TxMock)
+	m.On("Commit").Return(errors.New("commit error"))
+	m.On("Rollback").Return(nil)
+	transactional := TransactionalMiddleware(func(ctx context.Context) (driver.Tx, error) {
+		return m, nil
+	})
+	handler := transactional(RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+	})))
+	r := httptest.NewRequest("PUT", "/", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// The failed commit resets the response but keeps the request ID
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+	assert.JSONEq(t, `{"message":"internal server error","request_id":"req-1"}`, w.Body.String())
+}
+
+func TestRequestIDLogging(t *testing.T) {
+	t.Parallel()
+	type idLog struct {
+		RequestID string `json:"request_id"`
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	})
+	for _, logFirst := range []bool{false, true} {
+		writer := bytes.NewBufferString("")
+		logging := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)))
+		var h http.Handler
+		if logFirst {
+			h = logging(RequestIDMiddleware()(handler))
+		} else {
+			h = RequestIDMiddleware()(logging(handler))
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Request-ID", "req-2")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		parts := strings.Split(strings.TrimSpace(writer.String()), "\n")
+		assert.Len(t, parts, 2)
+		var reqLog, resLog idLog
+		assert.Nil(t, json.Unmarshal([]byte(parts[0]), &reqLog))
+		assert.Nil(t, json.Unmarshal([]byte(parts[1]), &resLog))
+		if logFirst {
+			// The request is logged before the ID is set up
+			assert.Empty(t, reqLog.RequestID)
+		} else {
+			assert.Equal(t, "req-2", reqLog.RequestID)
+		}
+		assert.Equal(t, "req-2", resLog.RequestID)
+	}
+}
+
+type testWrappedWriter struct {
+	http.ResponseWriter
+}
+
+func (w *testWrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,35 +1,178 @@
 package httpie
 
 import (
+	"bufio"
 	"bytes"
+	"io"
+	"maps"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// WatchMode controls how a WatchedResponseWriter handles the response
+type WatchMode int
+
+const (
+	// Capture the whole response until Apply, it can be reset at any time before that
+	WatchBuffered WatchMode = iota
+	// Write straight to the wrapped response while recording the status code and size
+	WatchPassThrough
+	// Capture up to BufferLimit bytes, then commit the response and stream the rest
+	WatchHybrid
+)
+
+// Options for NewWatchedResponseWriter
+type WatchOpts struct {
+	Mode WatchMode
+	// Bytes to capture in WatchHybrid mode before the response is committed
+	BufferLimit int
+}
+
+var DefaultWatchOpts = WatchOpts{Mode: WatchBuffered}
+
 // Wraps an http.ResponseWriter and watches for changes to the response
+// It implements http.Flusher, http.Hijacker and io.ReaderFrom and supports http.NewResponseController through Unwrap
+//
+// It behaves like net/http: the first status code wins, writing without one is a 200, and the headers
+// are captured when the status code is written. Until the response is committed, Reset restores the
+// headers to what they were before the handler ran.
 type WatchedResponseWriter struct {
 	statusCode   int
 	bytesWritten int
-	buffer       *bytes.Buffer
-	response     http.ResponseWriter
+	// Taken from a pool on the first captured write and returned once the response is committed
+	buffer   *bytes.Buffer
+	response http.ResponseWriter
+	opts     WatchOpts
+	// Number of nested middleware sharing this writer, only the outermost Apply commits the response
+	shares int
+	// The headers seen by the handler, nil until Header is called so untouched responses don't copy them
+	header http.Header
+	// The headers captured by WriteHeader, nil if the handler didn't change them
+	snapshot    http.Header
+	wroteHeader bool
+	// The status code and headers have been sent to the wrapped response, writes go straight through
+	committed   bool
+	committedAt time.Time
+	hijacked    bool
+	// Copies the start of the body for LoggingMiddleware
+	capture *bodyCapture
+	// The request ID set by RequestIDMiddleware and the header it is echoed on, kept across Reset
+	requestID       string
+	requestIDHeader string
 }
 
-// Capture the written status code
+// Capture the written status code and headers, in WatchPassThrough mode they are sent straight away
+// Only the first call counts, informational (1xx) status codes are sent to the wrapped response immediately
 func (w *WatchedResponseWriter) WriteHeader(statusCode int) {
+	if w.committed || w.hijacked {
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		// Informational responses carry the current headers (103 Early Hints) and don't replace the final response
+		w.applyHeader(w.header)
+		w.response.WriteHeader(statusCode)
+		return
+	}
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
 	w.statusCode = statusCode
+	// Changes to the headers after this point are ignored like net/http, so the handler gets a copy
+	w.snapshot = w.header
+	w.header = nil
+	if w.opts.Mode == WatchPassThrough {
+		w.commit()
+	}
 }
 
-// Delegate the Header method to the wrapped response
+// The headers of the response, changes are only sent to the wrapped response when it is committed
+// In WatchPassThrough mode nothing is captured so these are the headers of the wrapped response
 func (w *WatchedResponseWriter) Header() http.Header {
-	return w.response.Header()
+	if w.opts.Mode == WatchPassThrough {
+		return w.response.Header()
+	}
+	if w.header == nil {
+		if w.snapshot != nil {
+			w.header = w.snapshot.Clone()
+		} else {
+			w.header = w.response.Header().Clone()
+		}
+		if w.header == nil {
+			w.header = http.Header{}
+		}
+	}
+	return w.header
 }
 
-// Capture the written bytes to a buffer
+// Capture the written bytes to a buffer, once the response is committed they are written to the wrapped response
 func (w *WatchedResponseWriter) Write(b []byte) (int, error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.committed && w.overLimit(len(b)) {
+		if err := w.commit(); err != nil {
+			return 0, err
+		}
+	}
+	if w.capture != nil {
+		w.capture.write(b)
+	}
+	if w.committed {
+		n, err := w.response.Write(b)
+		w.bytesWritten += n
+		return n, err
+	}
 	w.bytesWritten += len(b)
-	return w.buffer.Write(b)
+	return w.buf().Write(b)
 }
 
-// Return the captured status code
+// Would capturing n more bytes go over the limit of the mode
+func (w *WatchedResponseWriter) overLimit(n int) bool {
+	switch w.opts.Mode {
+	case WatchPassThrough:
+		return true
+	case WatchHybrid:
+		return w.bufferLen()+n > w.opts.BufferLimit
+	}
+	return false
+}
+
+// Capture the bytes read from src, once the response is committed they are copied to the wrapped response
+func (w *WatchedResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if (w.opts.Mode == WatchHybrid && !w.committed) || w.capture != nil {
+		// Copy through Write so the response is committed once the limit is reached and the body is captured
+		return io.Copy(writerOnly{w}, src)
+	}
+	var n int64
+	var err error
+	if w.committed {
+		// Let the wrapped response use sendfile when it can
+		n, err = io.Copy(w.response, src)
+	} else {
+		n, err = w.buf().ReadFrom(src)
+	}
+	w.bytesWritten += int(n)
+	return n, err
+}
+
+// Hides ReadFrom so io.Copy uses Write
+type writerOnly struct {
+	io.Writer
+}
+
+// Return the captured status code, a response that was written without one is a 200
 func (w *WatchedResponseWriter) StatusCode() int {
 	return w.statusCode
 }
@@ -39,20 +182,179 @@ func (w *WatchedResponseWriter) BytesWritten() int {
 	return w.bytesWritten
 }
 
-// Apply the captured status code and bytes to the wrapped response
+// Has the status code been sent to the wrapped response, after that the response can no longer be reset or changed
+func (w *WatchedResponseWriter) Committed() bool {
+	return w.committed
+}
+
+// When the status code was sent to the wrapped response, zero if it has not been
+func (w *WatchedResponseWriter) CommittedAt() time.Time {
+	return w.committedAt
+}
+
+// Apply the captured status code, headers and bytes to the wrapped response
+// A response without a status code is a 200, calling Apply again does nothing
+// When the writer is shared by nested middleware only the outermost Apply commits the response
 func (w *WatchedResponseWriter) Apply() {
-	w.response.WriteHeader(w.statusCode)
-	w.response.Write(w.buffer.Bytes())
+	if w.shares > 0 {
+		w.shares--
+		return
+	}
+	if w.hijacked {
+		return
+	}
+	w.commit()
 }
 
-// Reset the status code, bytes written, and buffer
+// Reset the status code, headers, bytes written, and buffer to their state before the handler ran
+// The request ID header of RequestIDMiddleware is kept
+// A committed response has already been sent so it is left as is
 func (w *WatchedResponseWriter) Reset() {
+	if w.committed {
+		return
+	}
 	w.statusCode = 0
 	w.bytesWritten = 0
-	w.buffer.Reset()
+	if w.buffer != nil {
+		w.buffer.Reset()
+	}
+	if w.capture != nil {
+		w.capture.reset()
+	}
+	w.header = nil
+	w.snapshot = nil
+	w.wroteHeader = false
+	if w.requestIDHeader != "" {
+		w.Header().Set(w.requestIDHeader, w.requestID)
+	}
+}
+
+// Send the captured status code and bytes to the wrapped response and flush it to the client
+func (w *WatchedResponseWriter) Flush() {
+	w.FlushError()
+}
+
+// Send the captured status code and bytes to the wrapped response and flush it to the client, used by http.ResponseController
+func (w *WatchedResponseWriter) FlushError() error {
+	if w.hijacked {
+		return http.ErrHijacked
+	}
+	if err := w.commit(); err != nil {
+		return err
+	}
+	return http.NewResponseController(w.response).Flush()
+}
+
+// Take over the connection from the wrapped response, anything captured so far is discarded
+func (w *WatchedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.response).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = true
+	w.releaseBuffer()
+	return conn, rw, nil
+}
+
+// Return the wrapped response, used by http.ResponseController for deadlines and full duplex
+func (w *WatchedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.response
+}
+
+// Send the status code, headers and the captured bytes to the wrapped response
+func (w *WatchedResponseWriter) commit() error {
+	if w.committed {
+		return nil
+	}
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.statusCode = http.StatusOK
+		w.snapshot = w.header
+	}
+	w.committed = true
+	w.committedAt = time.Now().UTC()
+	w.applyHeader(w.snapshot)
+	w.response.WriteHeader(w.statusCode)
+	if w.bufferLen() == 0 {
+		w.releaseBuffer()
+		return nil
+	}
+	_, err := w.response.Write(w.buffer.Bytes())
+	w.releaseBuffer()
+	return err
+}
+
+var watchBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// Buffers that grew larger than this are left to the garbage collector so the pool doesn't hold on to them
+const maxPooledBufferSize = 64 * 1024
+
+func (w *WatchedResponseWriter) buf() *bytes.Buffer {
+	if w.buffer == nil {
+		w.buffer = watchBufferPool.Get().(*bytes.Buffer)
+	}
+	return w.buffer
+}
+
+func (w *WatchedResponseWriter) bufferLen() int {
+	if w.buffer == nil {
+		return 0
+	}
+	return w.buffer.Len()
+}
+
+func (w *WatchedResponseWriter) releaseBuffer() {
+	if w.buffer == nil {
+		return
+	}
+	if w.buffer.Cap() <= maxPooledBufferSize {
+		w.buffer.Reset()
+		watchBufferPool.Put(w.buffer)
+	}
+	w.buffer = nil
+}
+
+// Replace the headers of the wrapped response, nil leaves them untouched
+func (w *WatchedResponseWriter) applyHeader(header http.Header) {
+	if header == nil {
+		return
+	}
+	dst := w.response.Header()
+	clear(dst)
+	maps.Copy(dst, header)
+}
+
+// Create a new WatchedResponseWriter, the response is buffered until Apply unless another WatchMode is given
+// If response is already a WatchedResponseWriter that hasn't been committed it is shared instead of wrapped again,
+// using the mode that captures the most, so nested middleware don't copy the body twice
+func NewWatchedResponseWriter(response http.ResponseWriter, opts ...WatchOpts) *WatchedResponseWriter {
+	opt := DefaultWatchOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if ww, ok := response.(*WatchedResponseWriter); ok && !ww.committed && !ww.hijacked {
+		ww.shares++
+		ww.share(opt)
+		return ww
+	}
+	return &WatchedResponseWriter{response: response, opts: opt}
 }
 
-// Create a new WatchedResponseWriter
-func NewWatchedResponseWriter(response http.ResponseWriter) *WatchedResponseWriter {
-	return &WatchedResponseWriter{response: response, buffer: bytes.NewBuffer([]byte{})}
+// Switch to the mode that captures the most, WatchBuffered over WatchHybrid over WatchPassThrough
+func (w *WatchedResponseWriter) share(opt WatchOpts) {
+	switch {
+	case w.opts.Mode == WatchBuffered || opt.Mode == WatchPassThrough:
+		return
+	case opt.Mode == WatchBuffered:
+		w.opts = opt
+	case w.opts.Mode == WatchPassThrough:
+		// Headers set so far went straight to the wrapped response, they are now captured from there
+		w.opts = opt
+	default:
+		w.opts.BufferLimit = max(w.opts.BufferLimit, opt.BufferLimit)
+	}
 }
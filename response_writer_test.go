@@ -1,9 +1,19 @@
 package httpie
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -36,3 +46,403 @@ func TestWatchedResponseWriterReset(t *testing.T) {
 	assert.Equal(t, 0, w.BytesWritten())
 	assert.Equal(t, 0, len(rr.Body.String()))
 }
+
+func TestWatchedResponseWriterFlush(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("hello"))
+	assert.False(t, w.Committed())
+	assert.Equal(t, 0, rr.Body.Len())
+
+	w.Flush()
+	assert.True(t, w.Committed())
+	assert.True(t, rr.Flushed)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "hello", rr.Body.String())
+
+	// Once committed writes go straight through and the response can't be changed
+	w.Write([]byte(" world"))
+	assert.Equal(t, "hello world", rr.Body.String())
+	w.Reset()
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Apply()
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, http.StatusCreated, w.StatusCode())
+	assert.Equal(t, "hello world", rr.Body.String())
+	assert.Equal(t, 11, w.BytesWritten())
+}
+
+func TestWatchedResponseWriterFlushImplicitStatus(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	assert.Nil(t, http.NewResponseController(w).Flush())
+	assert.True(t, rr.Flushed)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusOK, w.StatusCode())
+}
+
+func TestWatchedResponseWriterReadFrom(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	n, err := io.Copy(w, strings.NewReader("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, 0, rr.Body.Len())
+	w.Flush()
+	n, err = w.ReadFrom(strings.NewReader(" world"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, "hello world", rr.Body.String())
+	assert.Equal(t, 11, w.BytesWritten())
+}
+
+func TestWatchedResponseWriterUnwrap(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	assert.Same(t, rr, w.Unwrap())
+	// The recorder doesn't support deadlines so the error comes from the innermost writer
+	assert.ErrorIs(t, http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Second)), http.ErrNotSupported)
+	_, _, err := w.Hijack()
+	assert.ErrorIs(t, err, http.ErrNotSupported)
+}
+
+func TestWatchedResponseWriterStreaming(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	handler := LoggingMiddleware(slog.New(slog.NewJSONHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		assert.Nil(t, rc.SetWriteDeadline(time.Now().Add(5*time.Second)))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		assert.Nil(t, rc.Flush())
+		<-release
+		w.Write([]byte("data: second\n\n"))
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "data: first\n", line)
+	close(release)
+	rest, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "\ndata: second\n\n", string(rest))
+}
+
+func TestWatchedResponseWriterHijack(t *testing.T) {
+	t.Parallel()
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("discarded"))
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		assert.Nil(t, err)
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+		_, err = w.Write([]byte("after"))
+		assert.ErrorIs(t, err, http.ErrHijacked)
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := NewWatchedResponseWriter(w)
+		defer ww.Apply()
+		inner.ServeHTTP(ww, r)
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "hijacked", string(body))
+}
+
+func TestWatchedResponseWriterPassThrough(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr, WatchOpts{Mode: WatchPassThrough})
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusAccepted)
+	assert.True(t, w.Committed())
+	assert.False(t, w.CommittedAt().IsZero())
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	w.Write([]byte("hello"))
+	assert.Equal(t, "hello", rr.Body.String())
+	io.Copy(w, strings.NewReader(" world"))
+	assert.Equal(t, "hello world", rr.Body.String())
+	w.Reset()
+	w.Apply()
+	assert.Equal(t, "hello world", rr.Body.String())
+	assert.Equal(t, http.StatusAccepted, w.StatusCode())
+	assert.Equal(t, 11, w.BytesWritten())
+	assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
+
+	rr = httptest.NewRecorder()
+	w = NewWatchedResponseWriter(rr, WatchOpts{Mode: WatchPassThrough})
+	w.Write([]byte("hello"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusOK, w.StatusCode())
+	assert.Equal(t, "hello", rr.Body.String())
+}
+
+func TestWatchedResponseWriterHybrid(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr, WatchOpts{Mode: WatchHybrid, BufferLimit: 5})
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("abc"))
+	w.Write([]byte("de"))
+	assert.False(t, w.Committed())
+	assert.True(t, w.CommittedAt().IsZero())
+	assert.Equal(t, 0, rr.Body.Len())
+
+	// Nothing has been sent so the response can still be replaced
+	w.Reset()
+	w.WriteHeader(http.StatusConflict)
+	w.Write([]byte("abcdef"))
+	assert.True(t, w.Committed())
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "abcdef", rr.Body.String())
+	w.Write([]byte("g"))
+	w.Apply()
+	assert.Equal(t, "abcdefg", rr.Body.String())
+	assert.Equal(t, 7, w.BytesWritten())
+}
+
+func TestWatchedResponseWriterHybridReadFrom(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr, WatchOpts{Mode: WatchHybrid, BufferLimit: 4})
+	n, err := w.ReadFrom(strings.NewReader("abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.False(t, w.Committed())
+
+	n, err = w.ReadFrom(strings.NewReader(strings.Repeat("x", 64*1024)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(64*1024), n)
+	assert.True(t, w.Committed())
+	assert.Equal(t, 3+64*1024, rr.Body.Len())
+	assert.Equal(t, 3+64*1024, w.BytesWritten())
+}
+
+func TestWatchedResponseWriterImplicitStatus(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	w.Write([]byte("hello"))
+	assert.Equal(t, http.StatusOK, w.StatusCode())
+	// Status codes after the first write are ignored
+	w.WriteHeader(http.StatusTeapot)
+	w.Apply()
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "hello", rr.Body.String())
+
+	// A handler that writes nothing is a 200
+	rr = httptest.NewRecorder()
+	w = NewWatchedResponseWriter(rr)
+	assert.Equal(t, 0, w.StatusCode())
+	assert.NotPanics(t, w.Apply)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusOK, w.StatusCode())
+}
+
+func TestWatchedResponseWriterFirstStatusWins(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusCreated, w.StatusCode())
+	w.Reset()
+	w.WriteHeader(http.StatusNotFound)
+	w.Apply()
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestWatchedResponseWriterHeaderSnapshot(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+	// Like net/http, headers changed after WriteHeader are not sent
+	w.Header().Set("X-Late", "true")
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Empty(t, rr.Header())
+	w.Apply()
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+	assert.Empty(t, rr.Header().Get("X-Late"))
+}
+
+func TestWatchedResponseWriterResetHeaders(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Request-Id", "abc")
+	w := NewWatchedResponseWriter(rr)
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Del("X-Request-Id")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("a,b"))
+
+	w.Reset()
+	assert.Equal(t, http.Header{"X-Request-Id": {"abc"}}, w.Header())
+	WriteErr(w, ErrInternal)
+	w.Apply()
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "abc", rr.Header().Get("X-Request-Id"))
+}
+
+func TestWatchedResponseWriterApplyIdempotent(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("hello"))
+	w.Apply()
+	w.Apply()
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "hello", rr.Body.String())
+}
+
+func TestWatchedResponseWriterInformational(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	w := NewWatchedResponseWriter(rr)
+	w.Header().Set("Link", "</style.css>; rel=preload")
+	w.WriteHeader(http.StatusEarlyHints)
+	assert.Equal(t, 0, w.StatusCode())
+	assert.False(t, w.Committed())
+	w.WriteHeader(http.StatusCreated)
+	w.Apply()
+	assert.Equal(t, http.StatusCreated, w.StatusCode())
+	assert.Equal(t, "</style.css>; rel=preload", rr.Header().Get("Link"))
+}
+
+func TestWatchedResponseWriterShared(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	outer := NewWatchedResponseWriter(rr, WatchOpts{Mode: WatchPassThrough})
+	inner := NewWatchedResponseWriter(outer, WatchOpts{Mode: WatchHybrid, BufferLimit: 16})
+	assert.Same(t, outer, inner)
+	// The mode that captures the most wins
+	innermost := NewWatchedResponseWriter(inner)
+	assert.Same(t, outer, innermost)
+
+	innermost.Header().Set("Content-Type", "text/plain")
+	innermost.WriteHeader(http.StatusCreated)
+	innermost.Write([]byte(strings.Repeat("x", 32)))
+	assert.False(t, outer.Committed())
+
+	innermost.Reset()
+	innermost.WriteHeader(http.StatusConflict)
+	innermost.Apply()
+	inner.Apply()
+	assert.False(t, outer.Committed())
+	outer.Apply()
+	assert.True(t, outer.Committed())
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Type"))
+
+	// Committed writers can't change mode so they are wrapped again
+	assert.NotSame(t, outer, NewWatchedResponseWriter(outer))
+}
+
+func TestWatchedResponseWriterSharedMiddleware(t *testing.T) {
+	t.Parallel()
+	writer := bytes.NewBufferString("")
+	logging := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)))
+	transactional := TransactionalMiddleware(func(ctx context.Context) (driver.Tx, error) {
+		m := new(TxMock)
+		m.On("Commit").Return(errors.New("commit error"))
+		m.On("Rollback").Return(nil)
+		return m, nil
+	})
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww, ok := w.(*WatchedResponseWriter)
+		assert.True(t, ok)
+		// Both middleware share a single writer around the recorder
+		assert.Same(t, rr, ww.Unwrap())
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})
+	logging(transactional(handler)).ServeHTTP(rr, httptest.NewRequest("PUT", "http://example.com", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.JSONEq(t, `{"message":"internal server error"}`, rr.Body.String())
+
+	var resLog responseLog
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &resLog))
+	assert.Equal(t, http.StatusInternalServerError, resLog.Status)
+	assert.Equal(t, rr.Body.Len(), resLog.Size)
+}
+
+// A response writer that discards everything so benchmarks only measure the middleware
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(statusCode int)  {}
+
+var benchmarkBody = []byte(strings.Repeat("x", 4096))
+
+func benchmarkHandler(b *testing.B, handler http.Handler) {
+	r := httptest.NewRequest("PUT", "http://example.com/path", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		handler.ServeHTTP(w, r)
+	}
+}
+
+var benchmarkEndpoint = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write(benchmarkBody)
+})
+
+func benchmarkTransactional() func(http.Handler) http.Handler {
+	return TransactionalMiddleware(func(ctx context.Context) (driver.Tx, error) {
+		return benchmarkTx{}, nil
+	})
+}
+
+type benchmarkTx struct{}
+
+func (benchmarkTx) Commit() error   { return nil }
+func (benchmarkTx) Rollback() error { return nil }
+
+func BenchmarkWatchedResponseWriter(b *testing.B) {
+	benchmarkHandler(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := NewWatchedResponseWriter(w)
+		benchmarkEndpoint.ServeHTTP(ww, r)
+		ww.Apply()
+	}))
+}
+
+func BenchmarkLoggingMiddleware(b *testing.B) {
+	benchmarkHandler(b, LoggingMiddleware(slog.New(slog.NewJSONHandler(io.Discard, nil)))(benchmarkEndpoint))
+}
+
+func BenchmarkTransactionalMiddleware(b *testing.B) {
+	benchmarkHandler(b, benchmarkTransactional()(benchmarkEndpoint))
+}
+
+func BenchmarkNestedMiddleware(b *testing.B) {
+	logging := LoggingMiddleware(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	benchmarkHandler(b, logging(benchmarkTransactional()(benchmarkEndpoint)))
+}
@@ -9,34 +9,64 @@ import (
 
 // Default attributes to log for an http request
 func DefaultLogRequestAttr(ctx context.Context, r *http.Request, start time.Time) []any {
-	return []any{
+	l := getLoggedRequest(ctx)
+	attrs := []any{
 		slog.String("method", r.Method),
-		slog.String("path", r.URL.Path),
-		slog.Any("query", r.URL.Query()),
+		slog.String("path", l.redact().Path(r.URL.Path)),
+		slog.Any("query", l.redact().Query(r.URL.Query())),
 		slog.Time("time", start),
 		slog.String("remote_addr", r.RemoteAddr),
 		slog.String("user_agent", r.UserAgent()),
-		slog.String("referer", r.Referer()),
+		slog.String("referer", l.redact().URL(r.Referer())),
 	}
+	if id := GetRequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	for _, attr := range logUserAttrs(ctx) {
+		attrs = append(attrs, attr)
+	}
+	if l != nil {
+		attrs = append(attrs, l.redact().headerAttrs("request_headers", r.Header, l.opts.LogRequestHeaders)...)
+	}
+	return append(attrs, logRequestBodyAttrs(ctx, r)...)
 }
 
 // Default attributes to log for a http response
 func DefaultLogResponseAttr(ctx context.Context, r *http.Request, ww *WatchedResponseWriter, start time.Time) []any {
-	// Get the current time and calculate the microseconds since the start time
-	now := time.Now().UTC()
-	diff := now.Sub(start).Microseconds()
-	return []any{
+	l := getLoggedRequest(ctx)
+	end := l.endTime()
+	duration := end.Sub(start)
+	attrs := []any{
 		slog.Int("status", ww.StatusCode()),
 		slog.String("method", r.Method),
-		slog.String("path", r.URL.Path),
-		slog.Any("query", r.URL.Query()),
-		slog.Time("time", now),
+		slog.String("path", l.redact().Path(r.URL.Path)),
+		slog.Any("query", l.redact().Query(r.URL.Query())),
+		slog.Time("time", end),
 		slog.String("remote_addr", r.RemoteAddr),
 		slog.String("user_agent", r.UserAgent()),
-		slog.String("referer", r.Referer()),
+		slog.String("referer", l.redact().URL(r.Referer())),
 		slog.Int("size", ww.BytesWritten()),
-		slog.Int64("duration", diff),
+		slog.Int64("duration", duration.Microseconds()),
+	}
+	if l.slow(duration) {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	// The echoed header covers a RequestIDMiddleware inside of LoggingMiddleware
+	if id := GetRequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	} else if id := responseRequestID(ww); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if route := logRoute(ctx); route != "" {
+		attrs = append(attrs, slog.String("route", route))
 	}
+	for _, attr := range logUserAttrs(ctx) {
+		attrs = append(attrs, attr)
+	}
+	if l != nil {
+		attrs = append(attrs, l.redact().headerAttrs("response_headers", ww.Header(), l.opts.LogResponseHeaders)...)
+	}
+	return append(attrs, logResponseBodyAttrs(ctx, r, ww)...)
 }
 
 // DefualtLogRequest logs the http request to a slog.Logger
@@ -45,10 +75,12 @@ func DefaultLogRequest(ctx context.Context, slogger *slog.Logger, r *http.Reques
 	slogger.Info("http.request", DefaultLogRequestAttr(ctx, r, start)...)
 }
 
-// DefaultLogResponse logs the http response to a slog.Logger
+// DefaultLogResponse logs the http response to a slog.Logger at the level for its status code, see LoggingOpts.StatusLevel
 func DefaultLogResponse(ctx context.Context, slogger *slog.Logger, r *http.Request, ww *WatchedResponseWriter, start time.Time) {
-	// Log the http response
-	slogger.Info("http.response", DefaultLogResponseAttr(ctx, r, ww, start)...)
+	l := getLoggedRequest(ctx)
+	level := l.responseLevel(ww.StatusCode(), l.endTime().Sub(start))
+	// Log the http response, without the request context so a ContextHandler doesn't add the request attributes twice
+	slogger.Log(context.Background(), level, "http.response", DefaultLogResponseAttr(ctx, r, ww, start)...)
 }
 
 // LoggingOpts are the options for the LoggingMiddleware
@@ -62,7 +94,69 @@ type LoggingOpts struct {
 	// Handler to log the response
 	OnResponse func(ctx context.Context, slogger *slog.Logger, r *http.Request, ww *WatchedResponseWriter, start time.Time)
 	// SetupContext is a function to setup the context before the request is logged, useful for things like user that might be set later
+	//
+	// Deprecated: downstream values can be set with SetBagValue and read in OnResponse with GetBagValue
 	SetupContext func(ctx context.Context) context.Context
+	// Should the request body be logged? The start of the body is read before the handler runs
+	LogRequestBody bool
+	// Should the response body be logged?
+	LogResponseBody bool
+	// Maximum bytes of a body to log, defaults to DEFAULT_BODY_LOG_LIMIT
+	BodyLogLimit int
+	// Media types whose bodies are logged (text/*, application/*+json), defaults to DefaultBodyContentTypes
+	BodyContentTypes []string
+	// Query keys, headers, path segments and body fields to redact from the default attributes
+	Redact RedactOpts
+	// Request headers to log as request_headers, denied headers are redacted
+	LogRequestHeaders []string
+	// Response headers to log as response_headers, denied headers are redacted
+	LogResponseHeaders []string
+	// Level of the response log for a status code, defaults to DefaultStatusLevel
+	StatusLevel func(status int) slog.Level
+	// Responses slower than this are logged at Warn or above with slow set, zero turns it off
+	SlowThreshold time.Duration
+	// Fraction of requests logged for paths matching a pattern ("/healthz": 0.01), paths that match none are all logged
+	// Patterns use path.Match on the request path (r.URL.Path), not the route patterns of http.ServeMux, since the
+	// request is sampled before it is routed. Errors and slow responses of requests that were not sampled are still logged
+	SampleRates map[string]float64
+	// Paths matching these patterns are not logged at all ("/healthz", "/jobs/*/status"), matched like SampleRates
+	SkipPaths []string
+}
+
+// The options and captured bodies of a request, stored in the context by LoggingMiddleware for the attribute functions
+// It is not kept in the RequestBag, which is shared, so nested LoggingMiddleware each see their own options
+type loggedRequest struct {
+	opts     LoggingOpts
+	bodies   *bodyRedactor
+	request  *bodyCapture
+	response *bodyCapture
+	// Was the request picked by SampleRates
+	sampled bool
+	// When the handler returned, the duration of the request is measured once from it
+	end time.Time
+}
+
+var loggedRequestCtxKey ctxKey = 4
+
+func getLoggedRequest(ctx context.Context) *loggedRequest {
+	l, _ := ctx.Value(loggedRequestCtxKey).(*loggedRequest)
+	return l
+}
+
+// When the handler returned, the current time outside of LoggingMiddleware or while the handler runs
+func (l *loggedRequest) endTime() time.Time {
+	if l == nil || l.end.IsZero() {
+		return time.Now().UTC()
+	}
+	return l.end
+}
+
+// The redaction options, only the defaults apply outside of LoggingMiddleware
+func (l *loggedRequest) redact() RedactOpts {
+	if l == nil {
+		return RedactOpts{}
+	}
+	return l.opts.Redact
 }
 
 // Default logging options
@@ -82,24 +176,49 @@ func LoggingMiddleware(slogger *slog.Logger, opts ...LoggingOpts) func(http.Hand
 	} else {
 		opt = DefaultLoggingOpts
 	}
+	bodies := newBodyRedactor(opt.Redact.BodyFields)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := r.Context()
+			// Values downstream middleware put in the bag are available to OnResponse
+			ctx := WithRequestBag(r.Context())
 			if opt.SetupContext != nil {
 				ctx = opt.SetupContext(ctx)
 			}
+			// Every record of the request carries the trace of TraceMiddleware
+			slogger := traceLogger(ctx, slogger)
+			// Skipped paths still get the bag and log context, only the request and response logs are left out
+			skipped := opt.skipped(r)
+			l := &loggedRequest{opts: opt, bodies: bodies, sampled: !skipped && opt.sampled(r)}
+			ctx = context.WithValue(ctx, loggedRequestCtxKey, l)
+			// The body is swapped on the new request, the caller's request is left untouched
+			r = r.WithContext(ctx)
+			if !skipped {
+				l.captureBodies(r)
+			}
+			if _, ok := GetBagValue(ctx, logRequestKey); !ok {
+				// http.ServeMux sets the route pattern on this request when it is the next handler
+				SetBagValue(ctx, logRequestKey, r)
+			}
 			var start time.Time
 			if opt.LogRequest || opt.LogResponse {
 				start = time.Now().UTC()
 			}
-			if opt.LogRequest {
+			if opt.LogRequest && l.sampled {
 				opt.OnRequest(ctx, slogger, r, start)
 			}
-			ww := NewWatchedResponseWriter(w)
-			next.ServeHTTP(ww, r.WithContext(ctx))
+			// Only the status code and size are needed so the response is streamed
+			ww := NewWatchedResponseWriter(w, WatchOpts{Mode: WatchPassThrough})
+			if l.response != nil {
+				ww.capture = l.response
+			}
+			next.ServeHTTP(ww, r)
 			ww.Apply()
-			if opt.LogResponse {
+			l.end = time.Now().UTC()
+			if skipped {
+				return
+			}
+			if opt.LogResponse && l.logResponse(ww.StatusCode(), l.end.Sub(start)) {
 				opt.OnResponse(ctx, slogger, r, ww, start)
 			}
 		})
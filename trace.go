@@ -0,0 +1,340 @@
+package httpie
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Headers defined by W3C Trace Context
+const (
+	TRACEPARENT_HEADER = "Traceparent"
+	TRACESTATE_HEADER  = "Tracestate"
+)
+
+// The trace is sampled, the only flag defined by W3C Trace Context level 1
+const TRACE_FLAG_SAMPLED byte = 0x01
+
+// Most list members kept from a tracestate header
+const MAX_TRACESTATE_MEMBERS = 32
+
+var ErrInvalidTraceparent = errors.New("httpie: invalid traceparent")
+
+// TraceID identifies a whole trace across services
+type TraceID [16]byte
+
+// SpanID identifies one operation within a trace
+type SpanID [8]byte
+
+// Is the trace ID set, all zeroes is not a valid trace ID
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// Return the trace ID as 32 lowercase hex characters
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Is the span ID set, all zeroes is not a valid span ID
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// Return the span ID as 16 lowercase hex characters
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Create a random trace ID
+func NewTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		randomBytes(id[:])
+	}
+	return id
+}
+
+// Create a random span ID
+func NewSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		randomBytes(id[:])
+	}
+	return id
+}
+
+// TraceContext is the trace a request belongs to as carried by the traceparent and tracestate headers
+type TraceContext struct {
+	TraceID TraceID
+	// The span of the caller, the parent of the span handling the request
+	SpanID SpanID
+	Flags  byte
+	// Vendor specific trace data, passed on as is
+	State string
+}
+
+// Is the trace sampled by the caller
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&TRACE_FLAG_SAMPLED != 0
+}
+
+// Return the traceparent header value (00-<trace-id>-<span-id>-<flags>)
+func (tc TraceContext) Traceparent() string {
+	var buf [55]byte
+	buf[0], buf[1], buf[2] = '0', '0', '-'
+	hex.Encode(buf[3:35], tc.TraceID[:])
+	buf[35] = '-'
+	hex.Encode(buf[36:52], tc.SpanID[:])
+	buf[52] = '-'
+	hex.Encode(buf[53:55], []byte{tc.Flags})
+	return string(buf[:])
+}
+
+// Set the traceparent and tracestate headers, used for outbound requests
+func (tc TraceContext) Inject(header http.Header) {
+	header.Set(TRACEPARENT_HEADER, tc.Traceparent())
+	if tc.State != "" {
+		header.Set(TRACESTATE_HEADER, tc.State)
+	} else {
+		header.Del(TRACESTATE_HEADER)
+	}
+}
+
+// Parse a traceparent header, versions after 00 are read as 00 like the spec asks
+func ParseTraceparent(traceparent string) (TraceContext, error) {
+	var tc TraceContext
+	if len(traceparent) < 55 || traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return tc, ErrInvalidTraceparent
+	}
+	version, ok := decodeLowerHex(traceparent[0:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(traceparent) != 55) {
+		return tc, ErrInvalidTraceparent
+	}
+	// Later versions may add fields after a dash
+	if len(traceparent) > 55 && traceparent[55] != '-' {
+		return tc, ErrInvalidTraceparent
+	}
+	traceID, ok := decodeLowerHex(traceparent[3:35])
+	if !ok {
+		return tc, ErrInvalidTraceparent
+	}
+	spanID, ok := decodeLowerHex(traceparent[36:52])
+	if !ok {
+		return tc, ErrInvalidTraceparent
+	}
+	flags, ok := decodeLowerHex(traceparent[53:55])
+	if !ok {
+		return tc, ErrInvalidTraceparent
+	}
+	copy(tc.TraceID[:], traceID)
+	copy(tc.SpanID[:], spanID)
+	tc.Flags = flags[0]
+	if !tc.TraceID.IsValid() || !tc.SpanID.IsValid() {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	return tc, nil
+}
+
+// Decode hex, the spec only allows lowercase
+func decodeLowerHex(s string) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Parse a tracestate header, invalid list members are dropped and at most MAX_TRACESTATE_MEMBERS are kept
+// Several tracestate headers are combined in order like the spec asks
+func ParseTracestate(values ...string) string {
+	var members []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			member = strings.Trim(member, " \t")
+			key, val, ok := strings.Cut(member, "=")
+			if !ok || !validTracestateKey(key) || !validTracestateValue(val) || seen[key] {
+				continue
+			}
+			seen[key] = true
+			members = append(members, member)
+			if len(members) == MAX_TRACESTATE_MEMBERS {
+				return strings.Join(members, ",")
+			}
+		}
+	}
+	return strings.Join(members, ",")
+}
+
+// Keys are lowercase letters, digits and _-*/ with an optional tenant@system form
+func validTracestateKey(key string) bool {
+	if key == "" || len(key) > 256 || !(key[0] >= 'a' && key[0] <= 'z' || key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range []byte(key) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '_' || c == '-' || c == '*' || c == '/' || c == '@':
+		default:
+			return false
+		}
+	}
+	return strings.Count(key, "@") <= 1
+}
+
+// Values are printable ASCII without comma or equals and don't end with a space
+func validTracestateValue(value string) bool {
+	if value == "" || len(value) > 256 || value[len(value)-1] == ' ' {
+		return false
+	}
+	for _, c := range []byte(value) {
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// Span records the handling of one request within a trace
+type Span struct {
+	TraceContext
+	// The span of the caller, zero if the request started the trace
+	ParentID SpanID
+	// The route pattern of the request (GET /users/{id}), the method and path until it is routed
+	Name  string
+	Start time.Time
+	// Set when the span is ended
+	End    time.Time
+	Status int
+}
+
+// Get a trace context for an outbound call, the child span ID becomes the parent of the remote span
+//
+//	span.Child().Inject(req.Header)
+func (s *Span) Child() TraceContext {
+	child := s.TraceContext
+	child.SpanID = NewSpanID()
+	return child
+}
+
+// Tracer starts and ends the span of each request, implement it to send spans to a tracing system like OpenTelemetry
+type Tracer interface {
+	// Start a span for the request, the parent is the zero TraceContext if the request didn't carry a valid traceparent
+	StartSpan(ctx context.Context, r *http.Request, parent TraceContext) (context.Context, *Span)
+	// End the span once the handler has returned
+	EndSpan(ctx context.Context, span *Span)
+}
+
+// W3CTracer keeps spans in memory, continuing the trace of the caller or starting a new sampled trace
+type W3CTracer struct{}
+
+func (W3CTracer) StartSpan(ctx context.Context, r *http.Request, parent TraceContext) (context.Context, *Span) {
+	span := &Span{
+		TraceContext: parent,
+		ParentID:     parent.SpanID,
+		Name:         spanName(r),
+		Start:        time.Now().UTC(),
+	}
+	if !parent.TraceID.IsValid() {
+		span.TraceID = NewTraceID()
+		span.Flags = TRACE_FLAG_SAMPLED
+	}
+	span.SpanID = NewSpanID()
+	return ctx, span
+}
+
+func (W3CTracer) EndSpan(ctx context.Context, span *Span) {
+	span.End = time.Now().UTC()
+}
+
+// The route pattern if the request was routed by http.ServeMux, the path otherwise
+func spanName(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// TraceOpts are the options for the TraceMiddleware
+type TraceOpts struct {
+	// Starts and ends spans, defaults to W3CTracer
+	Tracer Tracer
+	// Should incoming traceparent headers be ignored? Use this at the edge when clients are not trusted
+	IgnoreIncoming bool
+}
+
+// Default trace options
+var DefaultTraceOpts = TraceOpts{
+	Tracer: W3CTracer{},
+}
+
+var spanCtxKey ctxKey = 6
+
+// TraceMiddleware continues the W3C trace of the caller or starts a new one, the span is available with GetSpan
+func TraceMiddleware(opts ...TraceOpts) func(http.Handler) http.Handler {
+	opt := DefaultTraceOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Tracer == nil {
+		opt.Tracer = W3CTracer{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var parent TraceContext
+			if !opt.IgnoreIncoming {
+				if tc, err := ParseTraceparent(r.Header.Get(TRACEPARENT_HEADER)); err == nil {
+					parent = tc
+					parent.State = ParseTracestate(r.Header.Values(TRACESTATE_HEADER)...)
+				}
+			}
+			ctx, span := opt.Tracer.StartSpan(r.Context(), r, parent)
+			ctx = WithSpan(ctx, span)
+			ww := NewWatchedResponseWriter(w, WatchOpts{Mode: WatchPassThrough})
+			r = r.WithContext(ctx)
+			defer func() {
+				ww.Apply()
+				// http.ServeMux sets the route pattern on the request once it is routed
+				if r.Pattern != "" {
+					span.Name = r.Pattern
+				}
+				span.Status = ww.StatusCode()
+				opt.Tracer.EndSpan(ctx, span)
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// Get a context that carries the span for GetSpan
+func WithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanCtxKey, span)
+}
+
+// Get the span of the request from the context, nil if there is none
+func GetSpan(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanCtxKey).(*Span)
+	return span
+}
+
+// Set the trace headers for an outbound request with a new child span of the request in ctx
+// Nothing is set if the context has no span
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	if span := GetSpan(ctx); span != nil {
+		span.Child().Inject(header)
+	}
+}
+
+// Add the trace_id and span_id of the span in ctx to a logger
+func traceLogger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	span := GetSpan(ctx)
+	if span == nil {
+		return logger
+	}
+	return logger.With(slog.String("trace_id", span.TraceID.String()), slog.String("span_id", span.SpanID.String()))
+}
@@ -0,0 +1,219 @@
+package httpie
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// Runs before the parallel tests so replacing readRandom doesn't affect them
+func TestNewTraceIDRandomFailure(t *testing.T) {
+	defer func(read func([]byte) (int, error)) { readRandom = read }(readRandom)
+	readRandom = func(b []byte) (int, error) {
+		return 0, errors.New("entropy unavailable")
+	}
+	assert.True(t, NewTraceID().IsValid())
+	assert.True(t, NewSpanID().IsValid())
+	assert.NotEqual(t, NewTraceID(), NewTraceID())
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+	tc, err := ParseTraceparent(testTraceparent)
+	assert.Nil(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", tc.SpanID.String())
+	assert.True(t, tc.Sampled())
+	assert.Equal(t, testTraceparent, tc.Traceparent())
+
+	// Later versions are read as version 00
+	tc, err = ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.Nil(t, err)
+	assert.False(t, tc.Sampled())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", tc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01extra",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(invalid)
+		assert.ErrorIs(t, err, ErrInvalidTraceparent, invalid)
+	}
+}
+
+func TestParseTracestate(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", ParseTracestate("rojo=00f067aa0ba902b7, congo=t61rcWkgMzE"))
+	assert.Equal(t, "a=1,tenant@vendor=2,b=3", ParseTracestate("a=1,,Bad=x,tenant@vendor=2", "a=dup, b=3,c"))
+	members := make([]string, 40)
+	for i := range members {
+		members[i] = "k" + strings.Repeat("x", i) + "=v"
+	}
+	assert.Len(t, strings.Split(ParseTracestate(strings.Join(members, ",")), ","), MAX_TRACESTATE_MEMBERS)
+	assert.Equal(t, "", ParseTracestate())
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	t.Parallel()
+	var span *Span
+	var outbound http.Header
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		span = GetSpan(r.Context())
+		outbound = http.Header{}
+		InjectTraceContext(r.Context(), outbound)
+		w.WriteHeader(202)
+	})
+	handler := TraceMiddleware()(mux)
+
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("traceparent", testTraceparent)
+	r.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", span.ParentID.String())
+	assert.NotEqual(t, span.ParentID, span.SpanID)
+	assert.True(t, span.Sampled())
+	assert.Equal(t, "GET /users/{id}", span.Name)
+	assert.Equal(t, 202, span.Status)
+	assert.False(t, span.End.Before(span.Start))
+
+	child, err := ParseTraceparent(outbound.Get("traceparent"))
+	assert.Nil(t, err)
+	assert.Equal(t, span.TraceID, child.TraceID)
+	assert.NotEqual(t, span.SpanID, child.SpanID)
+	assert.Equal(t, "rojo=00f067aa0ba902b7", outbound.Get("tracestate"))
+
+	// A new trace is started without a valid traceparent
+	r = httptest.NewRequest("GET", "/users/2", nil)
+	r.Header.Set("traceparent", "garbage")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID.String())
+	assert.True(t, span.TraceID.IsValid())
+	assert.False(t, span.ParentID.IsValid())
+	assert.True(t, span.Sampled())
+	assert.Empty(t, outbound.Get("tracestate"))
+
+	// Untrusted callers can't pick the trace
+	r = httptest.NewRequest("GET", "/users/3", nil)
+	r.Header.Set("traceparent", testTraceparent)
+	TraceMiddleware(TraceOpts{IgnoreIncoming: true})(mux).ServeHTTP(httptest.NewRecorder(), r)
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID.String())
+
+	// Without the middleware nothing is injected
+	outbound = http.Header{}
+	InjectTraceContext(context.Background(), outbound)
+	assert.Empty(t, outbound)
+}
+
+type testTracer struct {
+	W3CTracer
+	ended []*Span
+}
+
+func (tr *testTracer) EndSpan(ctx context.Context, span *Span) {
+	tr.W3CTracer.EndSpan(ctx, span)
+	tr.ended = append(tr.ended, span)
+}
+
+func TestTraceMiddlewareTracer(t *testing.T) {
+	t.Parallel()
+	tracer := &testTracer{}
+	handler := TraceMiddleware(TraceOpts{Tracer: tracer})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteErr(w, ErrNotFound)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	assert.Len(t, tracer.ended, 1)
+	assert.Equal(t, "GET /missing", tracer.ended[0].Name)
+	assert.Equal(t, 404, tracer.ended[0].Status)
+}
+
+func TestTraceLogging(t *testing.T) {
+	t.Parallel()
+	type traceLog struct {
+		Msg     string
+		TraceID string `json:"trace_id"`
+		SpanID  string `json:"span_id"`
+	}
+	writer := bytes.NewBufferString("")
+	var span *Span
+	logging := LoggingMiddleware(slog.New(slog.NewJSONHandler(writer, nil)))
+	handler := TraceMiddleware()(logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span = GetSpan(r.Context())
+	})))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", testTraceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	parts := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	assert.Len(t, parts, 2)
+	for _, part := range parts {
+		var log traceLog
+		assert.Nil(t, json.Unmarshal([]byte(part), &log))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", log.TraceID, log.Msg)
+		assert.Equal(t, span.SpanID.String(), log.SpanID, log.Msg)
+	}
+}
+
+func TestTraceLoggerTransactional(t *testing.T) {
+	t.Parallel()
+	writer := bytes.NewBufferString("")
+	logger := slog.New(slog.NewJSONHandler(writer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	span := &Span{TraceContext: TraceContext{TraceID: NewTraceID(), SpanID: NewSpanID()}}
+	traceLogger(WithSpan(context.Background(), span), logger).Debug("middleware.Transactional")
+	assert.Contains(t, writer.String(), `"trace_id":"`+span.TraceID.String()+`"`)
+	assert.Contains(t, writer.String(), `"span_id":"`+span.SpanID.String()+`"`)
+
+	// Every record of TransactionalMiddleware has the span set up by TraceMiddleware
+	txWriter := bytes.NewBufferString("")
+	txLogger := slog.New(slog.NewJSONHandler(txWriter, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	m := new(TxMock)
+	m.On("Commit").Return(nil)
+	m.On("Rollback").Return(nil)
+	var handlerSpan *Span
+	handler := TraceMiddleware()(TransactionalMiddleware(func(ctx context.Context) (driver.Tx, error) {
+		return m, nil
+	}, TransactionalOpts{Logger: txLogger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = GetSpan(r.Context())
+		w.WriteHeader(201)
+	})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, 201, w.Code)
+	assert.NotNil(t, handlerSpan)
+	var states []string
+	for _, line := range strings.Split(strings.TrimSpace(txWriter.String()), "\n") {
+		var record struct {
+			Msg     string
+			State   string
+			TraceID string `json:"trace_id"`
+			SpanID  string `json:"span_id"`
+		}
+		assert.Nil(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, "middleware.Transactional", record.Msg)
+		assert.Equal(t, handlerSpan.TraceID.String(), record.TraceID)
+		assert.Equal(t, handlerSpan.SpanID.String(), record.SpanID)
+		states = append(states, record.State)
+	}
+	assert.Equal(t, []string{"start", "begin", "end"}, states)
+	m.AssertExpectations(t)
+}
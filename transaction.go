@@ -12,32 +12,65 @@ type ctxKey int
 
 var TransactionCtxKey ctxKey = 0
 
+// Get the transaction placed in the context by TransactionalMiddleware as the type returned by getTx
+//
+//	tx, ok := httpie.GetTransaction[*sql.Tx](ctx)
+func GetTransaction[T driver.Tx](ctx context.Context) (T, bool) {
+	tx, ok := ctx.Value(TransactionCtxKey).(T)
+	return tx, ok
+}
+
+// TransactionalOpts are the options for the TransactionalMiddleware
+type TransactionalOpts struct {
+	// Bytes of the response to buffer so it can be replaced with an error if the commit fails (0 buffers the whole response)
+	// Larger responses are streamed to the client before the transaction is committed
+	BufferLimit int
+	// Logger for the transaction states and errors, defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+// Default transactional options
+var DefaultTransactionalOpts = TransactionalOpts{}
+
 // TransactionMiddleware injects a transaction into the request context and handles the commit/rollback
-func TransactionalMiddleware(getTx func(ctx context.Context) (driver.Tx, error)) func(http.Handler) http.Handler {
+func TransactionalMiddleware(getTx func(ctx context.Context) (driver.Tx, error), opts ...TransactionalOpts) func(http.Handler) http.Handler {
+	opt := DefaultTransactionalOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	watchOpts := WatchOpts{Mode: WatchBuffered}
+	if opt.BufferLimit > 0 {
+		watchOpts = WatchOpts{Mode: WatchHybrid, BufferLimit: opt.BufferLimit}
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			slog.Debug("middleware.Transactional", slog.String("state", "start"))
+			logger := opt.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+			logger = traceLogger(r.Context(), logger)
+			logger.Debug("middleware.Transactional", slog.String("state", "start"))
 
 			// Skip HTTP methods that don't need a transaction
 			if r.Method == "GET" || r.Method == "OPTIONS" || r.Method == "HEAD" || r.Method == "TRACE" {
-				slog.Debug("middleware.Transactional", slog.String("state", "skip"), slog.Any("method", r.Method))
+				logger.Debug("middleware.Transactional", slog.String("state", "skip"), slog.Any("method", r.Method))
 				next.ServeHTTP(w, r)
 				return
 			}
 
 			// Wrap the response writer to capture the status code
-			ww := NewWatchedResponseWriter(w)
+			ww := NewWatchedResponseWriter(w, watchOpts)
 
 			defer func() {
 				ww.Apply()
 			}()
 
-			slog.Debug("middleware.Transactional", slog.String("state", "begin"))
+			logger.Debug("middleware.Transactional", slog.String("state", "begin"))
 			// Begin the transaction
 			tx, err := getTx(r.Context())
 			if err != nil {
-				slog.Error("middleware.Transactional", slog.String("state", "begin"), slog.Any("err", err))
-				WriteErr(ww, err)
+				logger.Error("middleware.Transactional", slog.String("state", "begin"), slog.Any("err", err))
+				WriteErrCtx(r.Context(), ww, err)
 				return
 			}
 
@@ -46,9 +79,12 @@ func TransactionalMiddleware(getTx func(ctx context.Context) (driver.Tx, error))
 				err := tx.Rollback()
 				if err != nil {
 					if !strings.Contains(err.Error(), "already been committed") {
-						slog.Error("middleware.Transactional", slog.String("state", "rollback"), slog.Any("err", err))
-						ww.Reset()
-						WriteErr(ww, err)
+						logger.Error("middleware.Transactional", slog.String("state", "rollback"), slog.Any("err", err))
+						// A flushed response has already been sent to the client
+						if !ww.Committed() {
+							ww.Reset()
+							WriteErrCtx(r.Context(), ww, err)
+						}
 					}
 				}
 			}()
@@ -62,20 +98,22 @@ func TransactionalMiddleware(getTx func(ctx context.Context) (driver.Tx, error))
 			// If we hit an error in the http handler then we don't want to commit the transaction
 			statusCode := ww.StatusCode()
 			if statusCode >= 400 {
-				slog.Error("middleware.Transactional", slog.String("state", "request"), slog.Int("status", statusCode))
+				logger.Error("middleware.Transactional", slog.String("state", "request"), slog.Int("status", statusCode))
 				return
 			}
 
 			// Commit the transaction
 			err = tx.Commit()
 			if err != nil {
-				slog.Error("middleware.Transactional", slog.String("state", "commit"), slog.Any("err", err))
-				ww.Reset()
-				WriteErr(ww, err)
+				logger.Error("middleware.Transactional", slog.String("state", "commit"), slog.Any("err", err))
+				if !ww.Committed() {
+					ww.Reset()
+					WriteErrCtx(r.Context(), ww, err)
+				}
 				return
 			}
 
-			slog.Debug("middleware.Transactional", slog.String("state", "end"))
+			logger.Debug("middleware.Transactional", slog.String("state", "end"))
 		})
 	}
 }
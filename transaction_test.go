@@ -179,3 +179,46 @@ func TestMiddlewareRollbackErrNormal(t *testing.T) {
 
 	m.AssertExpectations(t)
 }
+
+func TestGetTransaction(t *testing.T) {
+	t.Parallel()
+	m := new(TxMock)
+	ctx := context.WithValue(context.Background(), TransactionCtxKey, m)
+	tx, ok := GetTransaction[*TxMock](ctx)
+	assert.True(t, ok)
+	assert.Same(t, m, tx)
+	_, ok = GetTransaction[*testUniqueTx](ctx)
+	assert.False(t, ok)
+	_, ok = GetTransaction[driver.Tx](context.Background())
+	assert.False(t, ok)
+}
+
+func TestMiddlewareBufferLimit(t *testing.T) {
+	t.Parallel()
+	middleware := TransactionalMiddleware(func(ctx context.Context) (driver.Tx, error) {
+		m := new(TxMock)
+		m.On("Commit").Return(errors.New("commit error"))
+		m.On("Rollback").Return(nil)
+		return m, nil
+	}, TransactionalOpts{BufferLimit: 8})
+
+	// Responses within the limit can still be replaced when the commit fails
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		w.Write([]byte("small"))
+	})
+	w := httptest.NewRecorder()
+	middleware(handler).ServeHTTP(w, httptest.NewRequest("PUT", "http://example.com", nil))
+	assert.Equal(t, 500, w.Code)
+	assert.JSONEq(t, `{"message":"internal server error"}`, w.Body.String())
+
+	// Larger responses have already been streamed
+	handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		w.Write([]byte("a much larger response"))
+	})
+	w = httptest.NewRecorder()
+	middleware(handler).ServeHTTP(w, httptest.NewRequest("PUT", "http://example.com", nil))
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "a much larger response", w.Body.String())
+}
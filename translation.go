@@ -0,0 +1,147 @@
+package httpie
+
+import (
+	"cmp"
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+)
+
+// Translator holds the locales used for DefaultValidator messages, English is always available and used as the fallback
+var Translator = DefaultValidator.Translator()
+
+// Called from NewValidator once the tags are registered
+func (v *HttpValidator) registerDefaultTranslations() {
+	english := en.New()
+	v.translator = ut.New(english, english)
+	trans, _ := v.translator.GetTranslator(english.Locale())
+	en_translations.RegisterDefaultTranslations(v.engine, trans)
+	v.RegisterValidationMessage(english.Locale(), "securepassword", "{0} is not a secure password")
+	v.RegisterValidationMessage(english.Locale(), "notbreached", "{0} has appeared in a data breach, choose a different password")
+	for rule, message := range map[string]string{
+		PasswordEntropy:   "{0} is too easy to guess",
+		PasswordMinLength: "{0} must be at least {1} characters long",
+		PasswordMaxLength: "{0} must be at most {1} characters long",
+		PasswordLower:     "{0} must contain a lowercase letter",
+		PasswordUpper:     "{0} must contain an uppercase letter",
+		PasswordDigit:     "{0} must contain a digit",
+		PasswordSymbol:    "{0} must contain a symbol",
+		PasswordBanned:    "{0} contains a word that is not allowed",
+		PasswordContext:   "{0} must not contain your personal details",
+	} {
+		v.RegisterValidationMessage(english.Locale(), "securepassword."+rule, message)
+	}
+}
+
+// The locales used for validation messages
+func (v *HttpValidator) Translator() *ut.UniversalTranslator {
+	return v.translator
+}
+
+// Register a locale for DefaultValidator messages, register is called to add the default validator translations for it
+//
+//	httpie.RegisterValidationLocale(fr.New(), fr_translations.RegisterDefaultTranslations)
+func RegisterValidationLocale(locale locales.Translator, register func(v *validator.Validate, trans ut.Translator) error) error {
+	return DefaultValidator.RegisterValidationLocale(locale, register)
+}
+
+// Register the message for a DefaultValidator tag in a locale, {0} is replaced with the field name and {1} with the tag parameter
+func RegisterValidationMessage(locale string, tag string, message string) error {
+	return DefaultValidator.RegisterValidationMessage(locale, tag, message)
+}
+
+// Register a locale for validation messages, register is called to add the default validator translations for it
+func (v *HttpValidator) RegisterValidationLocale(locale locales.Translator, register func(v *validator.Validate, trans ut.Translator) error) error {
+	err := v.translator.AddTranslator(locale, true)
+	if err != nil {
+		return err
+	}
+	if register == nil {
+		return nil
+	}
+	trans, _ := v.translator.GetTranslator(locale.Locale())
+	return register(v.engine, trans)
+}
+
+// Register the message for a validation tag in a locale, {0} is replaced with the field name and {1} with the tag parameter
+func (v *HttpValidator) RegisterValidationMessage(locale string, tag string, message string) error {
+	trans, found := v.translator.GetTranslator(locale)
+	if !found {
+		return fmt.Errorf("httpie: validation locale %q is not registered", locale)
+	}
+	return v.engine.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+		return t.Add(tag, message, true)
+	}, func(t ut.Translator, fe validator.FieldError) string {
+		translated, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return translated
+	})
+}
+
+// Find the translator for the first registered language, falling back to English
+func (v *HttpValidator) findTranslator(langs []string) ut.Translator {
+	var candidates []string
+	for _, lang := range langs {
+		// BCP 47 tags (en-US) map to locale names (en_US), the base language is tried next
+		base, region, _ := strings.Cut(lang, "-")
+		base = strings.ToLower(base)
+		if region != "" {
+			candidates = append(candidates, base+"_"+strings.ToUpper(region))
+		}
+		candidates = append(candidates, base)
+	}
+	trans, _ := v.translator.FindTranslator(candidates...)
+	return trans
+}
+
+// Parse an Accept-Language header into its language tags ordered by q-value
+func parseAcceptLanguage(header string) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if params != "" {
+			// Reuse the media type parameter parser by giving it a placeholder type
+			_, parsed, err := mime.ParseMediaType("x/x;" + params)
+			if err != nil {
+				continue
+			}
+			if value, ok := parsed["q"]; ok {
+				q, err = strconv.ParseFloat(value, 64)
+				if err != nil {
+					continue
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		languages = append(languages, language{tag, q})
+	}
+	slices.SortStableFunc(languages, func(a, b language) int {
+		return cmp.Compare(b.q, a.q)
+	})
+	result := make([]string, len(languages))
+	for i, l := range languages {
+		result[i] = l.tag
+	}
+	return result
+}
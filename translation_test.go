@@ -0,0 +1,109 @@
+package httpie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/validator/v10"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTranslateStruct struct {
+	Name     string `json:"name" validate:"required"`
+	Password string `json:"password" validate:"required,securepassword"`
+	Age      int    `json:"age" validate:"max=100"`
+	Colour   string `json:"colour" validate:"omitempty,testcolour"`
+}
+
+// A validator of its own so the tests don't register tags and locales on DefaultValidator
+var testTranslateValidator = newTestTranslateValidator()
+
+func newTestTranslateValidator() *HttpValidator {
+	v := NewValidator()
+	v.Engine().RegisterValidation("testcolour", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "red"
+	})
+	v.RegisterValidationLocale(fr.New(), fr_translations.RegisterDefaultTranslations)
+	return v
+}
+
+func TestValidateLangEnglish(t *testing.T) {
+	t.Parallel()
+	err := testTranslateValidator.ValidateLang(testTranslateStruct{Password: "weak", Age: 200, Colour: "blue"}, "en-GB")
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]string{
+		"name":     "required",
+		"password": "securepassword",
+		"age":      "max=100",
+		"colour":   "testcolour",
+	}, err.ValidationErrors())
+	messageErr, ok := err.(IErrHttpValidationMessages)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"name":     "name is a required field",
+		"password": "password is not a secure password",
+		"age":      "age must be 100 or less",
+	}, messageErr.ValidationMessages())
+}
+
+func TestValidateLangFallback(t *testing.T) {
+	t.Parallel()
+	err := testTranslateValidator.ValidateLang(testTranslateStruct{Password: "test.test.test12345678"}, "de-DE", "ja")
+	assert.NotNil(t, err)
+	messageErr := err.(IErrHttpValidationMessages)
+	assert.Equal(t, map[string]string{"name": "name is a required field"}, messageErr.ValidationMessages())
+}
+
+func TestValidateRequestAcceptLanguage(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("POST", "http://example.com", nil)
+	r.Header.Set("Accept-Language", "de;q=0.5, fr-CA, en;q=0.8")
+	err := testTranslateValidator.ValidateRequest(r, testTranslateStruct{Password: "test.test.test12345678"})
+	assert.NotNil(t, err)
+	messageErr := err.(IErrHttpValidationMessages)
+	assert.Equal(t, map[string]string{"name": "name est un champ obligatoire"}, messageErr.ValidationMessages())
+}
+
+func TestValidateNoMessages(t *testing.T) {
+	t.Parallel()
+	err := testTranslateValidator.Validate(testTranslateStruct{Password: "test.test.test12345678"})
+	assert.NotNil(t, err)
+	assert.Nil(t, err.(IErrHttpValidationMessages).ValidationMessages())
+}
+
+func TestRegisterValidationMessage(t *testing.T) {
+	t.Parallel()
+	v := newTestTranslateValidator()
+	v.Engine().RegisterValidation("testshape", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == fl.Param()
+	})
+	assert.NoError(t, v.RegisterValidationMessage("en", "testshape", "{0} must be a {1}"))
+	assert.NoError(t, v.RegisterValidationMessage("fr", "testshape", "{0} doit être un {1}"))
+	assert.Error(t, v.RegisterValidationMessage("xx", "testshape", "{0}"))
+	data := struct {
+		Shape string `json:"shape" validate:"testshape=circle"`
+	}{Shape: "square"}
+	err := v.ValidateLang(data, "en")
+	assert.Equal(t, map[string]string{"shape": "shape must be a circle"}, err.(IErrHttpValidationMessages).ValidationMessages())
+	err = v.ValidateLang(data, "fr")
+	assert.Equal(t, map[string]string{"shape": "shape doit être un circle"}, err.(IErrHttpValidationMessages).ValidationMessages())
+}
+
+func TestWriteErrValidationMessages(t *testing.T) {
+	t.Parallel()
+	err := testTranslateValidator.ValidateLang(testTranslateStruct{Password: "test.test.test12345678"}, "en")
+	w := httptest.NewRecorder()
+	WriteErr(w, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"name":"required"},"messages":{"name":"name is a required field"}}`, w.Body.String())
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"fr-CH", "fr", "en", "de"}, parseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5"))
+	assert.Equal(t, []string{"en"}, parseAcceptLanguage("en, de;q=0, ja;q=bad"))
+	assert.Empty(t, parseAcceptLanguage(""))
+}
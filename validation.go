@@ -1,33 +1,170 @@
 package httpie
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"reflect"
 	"strings"
+	"sync"
 
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
-
-	passwordvalidator "github.com/wagslane/go-password-validator"
 )
 
-var Validator *validator.Validate
+// HttpValidator validates structs with its own tags, field names, password policies, breached password store and translations
+// Create one with NewValidator, the package level Validate functions use DefaultValidator
+type HttpValidator struct {
+	engine     *validator.Validate
+	translator *ut.UniversalTranslator
+	// Names fields in validation errors, Bind uses it to find the keys it renames
+	tagName validator.TagNameFunc
 
-func init() {
-	Validator = validator.New(validator.WithRequiredStructEnabled())
-	Validator.RegisterTagNameFunc(func(field reflect.StructField) string {
-		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
-		if name == "-" || name == "" {
-			return field.Name
-		}
-		return name
-	})
-	Validator.RegisterValidation("securepassword", SecurePasswordValidator)
+	passwordPoliciesMu sync.RWMutex
+	passwordPolicies   map[string]PasswordPolicy
+
+	breachedPasswordsMu   sync.RWMutex
+	breachedPasswords     BreachedPasswordStore
+	breachedPasswordsOpts BreachedPasswordOpts
+}
+
+// Options for NewValidator
+type ValidatorOpts struct {
+	// Names fields in validation errors, defaults to JsonTagName
+	TagNameFunc validator.TagNameFunc
+	// Password policies by name, the empty name replaces the default securepassword policy
+	PasswordPolicies map[string]PasswordPolicy
+	// Store used by the notbreached validation
+	BreachedPasswords    BreachedPasswordStore
+	BreachedPasswordOpts BreachedPasswordOpts
+}
+
+var DefaultValidatorOpts = ValidatorOpts{}
+
+// Create a validator with the securepassword and notbreached tags and English messages
+func NewValidator(opts ...ValidatorOpts) *HttpValidator {
+	opt := DefaultValidatorOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	v := &HttpValidator{
+		engine: validator.New(validator.WithRequiredStructEnabled()),
+		passwordPolicies: map[string]PasswordPolicy{
+			"": {MinEntropy: MIN_ENTROPY},
+		},
+		breachedPasswords:     opt.BreachedPasswords,
+		breachedPasswordsOpts: opt.BreachedPasswordOpts,
+	}
+	for name, policy := range opt.PasswordPolicies {
+		v.passwordPolicies[name] = policy
+	}
+	v.tagName = opt.TagNameFunc
+	if v.tagName == nil {
+		v.tagName = JsonTagName
+	}
+	v.engine.RegisterTagNameFunc(v.tagName)
+	v.engine.RegisterValidationCtx("securepassword", v.securePasswordValidatorCtx)
+	v.engine.RegisterValidationCtx("notbreached", v.notBreachedValidatorCtx)
+	v.registerDefaultTranslations()
+	return v
+}
+
+// Name a field by its json tag, falling back to the Go field name
+func JsonTagName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" || name == "" {
+		return field.Name
+	}
+	return name
 }
 
+// The underlying go-playground validator, used to register custom tags, struct level validations and aliases
+func (v *HttpValidator) Engine() *validator.Validate {
+	return v.engine
+}
+
+// DefaultValidator is used by Validate, Handle, Bind and the package level registration functions
+var DefaultValidator = NewValidator()
+
+// Validator is the engine of DefaultValidator, custom tags registered on it are available to Validate
+var Validator = DefaultValidator.Engine()
+
 // Validate an object and return a validation error if any
+// Fields are keyed by their full path using json names (items[3].price, address.zip)
 func Validate(s any) IErrHttpValidation {
-	var validations = map[string]string{}
-	err := Validator.Struct(s)
+	return DefaultValidator.Validate(s)
+}
+
+// Validate an object and return a validation error with messages in the first matching language if any
+// Languages use BCP 47 tags (en, en-US), English is used if none of them are registered
+func ValidateLang(s any, langs ...string) IErrHttpValidation {
+	return DefaultValidator.ValidateLang(s, langs...)
+}
+
+// Validate an object and return a validation error with messages in the language from the Accept-Language header if any
+func ValidateRequest(r *http.Request, s any) IErrHttpValidation {
+	return DefaultValidator.ValidateRequest(r, s)
+}
+
+// Validate an object with the request context and return an error if any
+// Validation failures are returned as an ErrHttpValidation, errors from context-aware rules are returned as is.
+// When languages are given the validation error has messages in the first matching language.
+func ValidateCtx(ctx context.Context, s any, langs ...string) error {
+	return DefaultValidator.ValidateCtx(ctx, s, langs...)
+}
+
+// Validate an object and return a validation error if any
+// Context-aware rules run with a background context, a rule that errors is logged and reported as a failure
+func (v *HttpValidator) Validate(s any) IErrHttpValidation {
+	return v.validateLogged(context.Background(), s, nil)
+}
+
+// Validate an object and return a validation error with messages in the first matching language if any
+func (v *HttpValidator) ValidateLang(s any, langs ...string) IErrHttpValidation {
+	return v.validateLogged(context.Background(), s, v.findTranslator(langs))
+}
+
+// Validate an object and return a validation error with messages in the language from the Accept-Language header if any
+func (v *HttpValidator) ValidateRequest(r *http.Request, s any) IErrHttpValidation {
+	return v.validateLogged(r.Context(), s, v.findTranslator(parseAcceptLanguage(r.Header.Get("Accept-Language"))))
+}
+
+// Validate an object with the request context and return an error if any
+func (v *HttpValidator) ValidateCtx(ctx context.Context, s any, langs ...string) error {
+	var trans ut.Translator
+	if len(langs) > 0 {
+		trans = v.findTranslator(langs)
+	}
+	validationErr, err := v.validate(ctx, s, trans)
+	if err != nil {
+		return err
+	}
+	if validationErr != nil {
+		return validationErr
+	}
+	return nil
+}
+
+func (v *HttpValidator) validateLogged(ctx context.Context, s any, trans ut.Translator) IErrHttpValidation {
+	validationErr, err := v.validate(ctx, s, trans)
+	if err != nil {
+		slog.ErrorContext(ctx, "httpie.Validate", slog.Any("err", err))
+	}
+	return validationErr
+}
+
+func (v *HttpValidator) validate(ctx context.Context, s any, trans ut.Translator) (IErrHttpValidation, error) {
+	var validations = map[string][]string{}
+	var messages = map[string][]string{}
+	failures := &passwordFailures{}
+	ruleErrs := &validationRuleErrs{}
+	ctx = context.WithValue(ctx, passwordFailuresCtxKey, failures)
+	ctx = context.WithValue(ctx, validationRuleErrsCtxKey, ruleErrs)
+	err := v.engine.StructCtx(ctx, s)
 	if err != nil {
+		root := reflect.Indirect(reflect.ValueOf(s)).Type().Name()
 		for _, err := range err.(validator.ValidationErrors) {
 			tag := err.Tag()
 			param := err.Param()
@@ -35,18 +172,130 @@ func Validate(s any) IErrHttpValidation {
 			if param != "" {
 				message += "=" + param
 			}
-			validations[err.Field()] = message
+			field := fieldPath(err.Namespace(), root)
+			validations[field] = append(validations[field], message)
+			if trans != nil {
+				// Tags without a registered message fall back to the raw validator error, which is not useful to clients
+				if translated := err.Translate(trans); translated != err.Error() {
+					messages[field] = append(messages[field], translated)
+				}
+			}
+			if tag == "securepassword" {
+				// Each broken password rule is reported after the tag itself (securepassword.min_length=12)
+				password, _ := err.Value().(string)
+				for _, code := range failures.take(password, param) {
+					validations[field] = append(validations[field], tag+"."+code)
+					if trans != nil {
+						rule, ruleParam, _ := strings.Cut(code, "=")
+						if translated, translateErr := trans.T(tag+"."+rule, err.Field(), ruleParam); translateErr == nil {
+							messages[field] = append(messages[field], translated)
+						}
+					}
+				}
+			}
 		}
-		return NewErrHttpValidation(validations)
+		validationErr := NewErrHttpValidationList(validations)
+		if trans != nil {
+			validationErr = validationErr.WithMessages(messages)
+		}
+		return validationErr, ruleErrs.err()
+	}
+	return nil, ruleErrs.err()
+}
+
+// Strip the root struct name from a validator namespace (Order.items[3].price becomes items[3].price)
+// Anonymous structs have no root name in the namespace
+func fieldPath(namespace string, root string) string {
+	if root == "" {
+		return namespace
+	}
+	return strings.TrimPrefix(namespace, root+".")
+}
+
+var validatorCtxKey ctxKey = 2
+
+// ValidatorMiddleware makes Handle and Bind use v for requests handled by next
+func ValidatorMiddleware(v *HttpValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithValidator(r.Context(), v)))
+		})
+	}
+}
+
+// Get a context that carries v for GetValidator
+func WithValidator(ctx context.Context, v *HttpValidator) context.Context {
+	return context.WithValue(ctx, validatorCtxKey, v)
+}
+
+// Get the validator from the context, DefaultValidator is returned if there is none
+func GetValidator(ctx context.Context) *HttpValidator {
+	if v, ok := ctx.Value(validatorCtxKey).(*HttpValidator); ok && v != nil {
+		return v
+	}
+	return DefaultValidator
+}
+
+// ValidationFuncCtx is a context-aware validation rule, for example one that queries the database with the transaction from GetTransaction
+// Return false to report the tag as a validation failure, errors abort the request instead of failing the field
+type ValidationFuncCtx func(ctx context.Context, fl validator.FieldLevel) (bool, error)
+
+// Register a context-aware rule on DefaultValidator, see HttpValidator.RegisterValidationCtx
+func RegisterValidationCtx(tag string, fn ValidationFuncCtx, callValidationEvenIfNull ...bool) error {
+	return DefaultValidator.RegisterValidationCtx(tag, fn, callValidationEvenIfNull...)
+}
+
+// Register a context-aware rule, its failures are reported with the static tags (unique=users.email)
+// The rule receives the context given to ValidateCtx, which is the request context for Handle and Bind
+func (v *HttpValidator) RegisterValidationCtx(tag string, fn ValidationFuncCtx, callValidationEvenIfNull ...bool) error {
+	return v.engine.RegisterValidationCtx(tag, func(ctx context.Context, fl validator.FieldLevel) bool {
+		// Skip the remaining rules once the request is gone
+		if err := ctx.Err(); err != nil {
+			recordValidationRuleErr(ctx, err)
+			return false
+		}
+		ok, err := fn(ctx, fl)
+		if err != nil {
+			recordValidationRuleErr(ctx, validationRuleErr(fl, err))
+			return false
+		}
+		return ok
+	}, callValidationEvenIfNull...)
+}
+
+// Collects the errors of context-aware rules while validating
+type validationRuleErrs struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (e *validationRuleErrs) err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return errors.Join(e.errs...)
+}
+
+var validationRuleErrsCtxKey ctxKey = 3
+
+// Name the tag and field in the error of a rule
+func validationRuleErr(fl validator.FieldLevel, err error) error {
+	return fmt.Errorf("httpie: validation %s on %s: %w", fl.GetTag(), fl.FieldName(), err)
+}
+
+func recordValidationRuleErr(ctx context.Context, err error) {
+	if ruleErrs, ok := ctx.Value(validationRuleErrsCtxKey).(*validationRuleErrs); ok {
+		ruleErrs.mu.Lock()
+		ruleErrs.errs = append(ruleErrs.errs, err)
+		ruleErrs.mu.Unlock()
 	}
-	return nil
 }
 
 const MIN_ENTROPY = 60
 
-// Custom validator for secure passwords - ensures entropy is greater than 60
+// Custom validator for secure passwords - checks the DefaultValidator password policy named by the tag parameter
+// The default policy ensures entropy is greater than 60, see RegisterPasswordPolicy
+// An unknown policy fails the field
 func SecurePasswordValidator(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-	err := passwordvalidator.Validate(password, MIN_ENTROPY)
-	return err == nil
+	codes, err := DefaultValidator.checkPasswordField(fl)
+	return err == nil && len(codes) == 0
 }
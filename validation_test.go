@@ -1,8 +1,15 @@
 package httpie
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,3 +47,210 @@ func TestValidatorErrSecurePassword(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "securepassword", err.ValidationErrors()["Password"])
 }
+
+type testValidateAddress struct {
+	Zip string `json:"zip" validate:"required,len=5"`
+}
+
+type testValidateItem struct {
+	Price float64 `json:"price" validate:"gt=0"`
+}
+
+type testValidateOrder struct {
+	Price   float64             `json:"price" validate:"gt=0"`
+	Address testValidateAddress `json:"address"`
+	Items   []testValidateItem  `json:"items" validate:"dive"`
+	Tags    []string            `json:"tags" validate:"dive,required"`
+}
+
+func TestValidatorNestedPaths(t *testing.T) {
+	err := Validate(testValidateOrder{
+		Address: testValidateAddress{Zip: "123"},
+		Items:   []testValidateItem{{Price: 1}, {Price: 0}, {Price: 2}, {Price: -1}},
+		Tags:    []string{"a", ""},
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]string{
+		"price":          "gt=0",
+		"address.zip":    "len=5",
+		"items[1].price": "gt=0",
+		"items[3].price": "gt=0",
+		"tags[1]":        "required",
+	}, err.ValidationErrors())
+}
+
+type testValidateRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func TestValidatorMultipleFailures(t *testing.T) {
+	t.Parallel()
+	v := NewValidator()
+	v.Engine().RegisterStructValidation(func(sl validator.StructLevel) {
+		r := sl.Current().Interface().(testValidateRange)
+		if r.Min > r.Max {
+			sl.ReportError(r.Min, "min", "Min", "ltefield", "max")
+		}
+		if r.Min < 0 {
+			sl.ReportError(r.Min, "min", "Min", "gte", "0")
+		}
+	}, testValidateRange{})
+	err := v.Validate(testValidateRange{Min: -1, Max: -2})
+	assert.NotNil(t, err)
+	listErr, ok := err.(IErrHttpValidationList)
+	assert.True(t, ok)
+	assert.Equal(t, map[string][]string{"min": {"ltefield=max", "gte=0"}}, listErr.ValidationErrorList())
+	assert.Equal(t, map[string]string{"min": "ltefield=max"}, err.ValidationErrors())
+}
+
+type testValidatorInstanceStruct struct {
+	Name     string `json:"name" validate:"required,testinstance"`
+	Password string `json:"password" validate:"securepassword=pin"`
+}
+
+func newTestValidator() *HttpValidator {
+	v := NewValidator(ValidatorOpts{
+		TagNameFunc: func(field reflect.StructField) string {
+			return strings.ToUpper(field.Name)
+		},
+		PasswordPolicies: map[string]PasswordPolicy{
+			"pin": {MinLength: 4, RequireDigit: true},
+		},
+	})
+	v.Engine().RegisterValidation("testinstance", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() != "invalid"
+	})
+	v.RegisterValidationMessage("en", "testinstance", "{0} is not allowed")
+	return v
+}
+
+func TestNewValidator(t *testing.T) {
+	t.Parallel()
+	v := newTestValidator()
+	assert.Nil(t, v.Validate(testValidatorInstanceStruct{Name: "valid", Password: "1234"}))
+
+	err := v.ValidateLang(testValidatorInstanceStruct{Name: "invalid", Password: "12"}, "en")
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string][]string{
+		"NAME":     {"testinstance"},
+		"PASSWORD": {"securepassword=pin", "securepassword.min_length=4"},
+	}, err.(IErrHttpValidationList).ValidationErrorList())
+	assert.Equal(t, map[string]string{
+		"NAME":     "NAME is not allowed",
+		"PASSWORD": "PASSWORD is not a secure password",
+	}, err.(IErrHttpValidationMessages).ValidationMessages())
+}
+
+func TestNewValidatorIsolated(t *testing.T) {
+	t.Parallel()
+	newTestValidator()
+	// Tags and policies registered on an instance are unknown to the default validator
+	assert.Panics(t, func() { Validate(testValidatorInstanceStruct{Name: "valid", Password: "1234"}) })
+	assert.NotNil(t, NewValidator().Validate(struct {
+		Password string `validate:"securepassword=pin"`
+	}{"1234"}))
+}
+
+func TestValidatorMiddleware(t *testing.T) {
+	t.Parallel()
+	v := newTestValidator()
+	assert.Same(t, DefaultValidator, GetValidator(context.Background()))
+	assert.Same(t, v, GetValidator(WithValidator(context.Background(), v)))
+
+	handler := ValidatorMiddleware(v)(Handle(func(ctx context.Context, req testValidatorInstanceStruct) (string, error) {
+		return req.Name, nil
+	}))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Name":"invalid","Password":"1234"}`)))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"NAME":"testinstance"}}`, res.Body.String())
+}
+
+type testUniqueTx struct {
+	taken map[string]bool
+	err   error
+}
+
+func (tx *testUniqueTx) Commit() error   { return nil }
+func (tx *testUniqueTx) Rollback() error { return nil }
+
+type testValidateCtxStruct struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email" validate:"required,available=users.email"`
+}
+
+func newTestCtxValidator() *HttpValidator {
+	v := NewValidator()
+	v.RegisterValidationCtx("available", func(ctx context.Context, fl validator.FieldLevel) (bool, error) {
+		tx, ok := GetTransaction[*testUniqueTx](ctx)
+		if !ok {
+			return false, errors.New("no transaction")
+		}
+		if tx.err != nil {
+			return false, tx.err
+		}
+		return !tx.taken[fl.Param()+"="+fl.Field().String()], nil
+	})
+	return v
+}
+
+func TestValidateCtx(t *testing.T) {
+	t.Parallel()
+	v := newTestCtxValidator()
+	ctx := context.WithValue(context.Background(), TransactionCtxKey, &testUniqueTx{taken: map[string]bool{"users.email=taken@example.com": true}})
+
+	err := v.ValidateCtx(ctx, testValidateCtxStruct{Email: "taken@example.com"})
+	var validationErr ErrHttpValidation
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string]string{"name": "required", "email": "available=users.email"}, validationErr.ValidationErrors())
+
+	assert.Nil(t, v.ValidateCtx(ctx, testValidateCtxStruct{Name: "test", Email: "free@example.com"}))
+
+	err = v.ValidateCtx(ctx, testValidateCtxStruct{Email: "taken@example.com"}, "en")
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string]string{"name": "name is a required field"}, validationErr.ValidationMessages())
+}
+
+func TestValidateCtxRuleErr(t *testing.T) {
+	t.Parallel()
+	v := newTestCtxValidator()
+	dbErr := errors.New("connection reset")
+	ctx := context.WithValue(context.Background(), TransactionCtxKey, &testUniqueTx{err: dbErr})
+
+	err := v.ValidateCtx(ctx, testValidateCtxStruct{Name: "test", Email: "free@example.com"})
+	assert.ErrorIs(t, err, dbErr)
+	assert.EqualError(t, err, "httpie: validation available on email: connection reset")
+	assert.Equal(t, 500, MapErr(err).StatusCode())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = v.ValidateCtx(cancelled, testValidateCtxStruct{Name: "test", Email: "free@example.com"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Without a context the rule error is logged and the field fails
+	validationErr := v.Validate(testValidateCtxStruct{Name: "test", Email: "free@example.com"})
+	assert.NotNil(t, validationErr)
+	assert.Equal(t, map[string]string{"email": "available=users.email"}, validationErr.ValidationErrors())
+}
+
+func TestValidateCtxHandle(t *testing.T) {
+	t.Parallel()
+	v := newTestCtxValidator()
+	tx := &testUniqueTx{taken: map[string]bool{"users.email=taken@example.com": true}}
+	handler := ValidatorMiddleware(v)(Handle(func(ctx context.Context, req testValidateCtxStruct) (string, error) {
+		return req.Email, nil
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"test","email":"taken@example.com"}`))
+	r = r.WithContext(context.WithValue(r.Context(), TransactionCtxKey, tx))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, r)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"email":"available=users.email"}}`, res.Body.String())
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"test","email":"taken@example.com"}`))
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, r)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+}
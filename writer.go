@@ -1,10 +1,31 @@
 package httpie
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 )
 
+// ErrOpts are the options used when rendering errors with WriteErr
+type ErrOpts struct {
+	// Render errors as RFC 9457 problem details (application/problem+json)
+	ProblemDetails bool
+	// Render every failure for each field as a list, rather than just the first
+	ValidationErrorList bool
+	// Adds members to the rendered error, they never override the standard members
+	// The context is the request context when written with WriteErrCtx or a negotiated writer, so values from the request bag can be added
+	Extensions func(ctx context.Context, err IErrHttp) map[string]any
+}
+
+// Default error rendering options, change these to configure WriteErr globally
+var DefaultErrOpts = ErrOpts{
+	ProblemDetails:      false,
+	ValidationErrorList: false,
+}
+
 // Used for operations that resulted in a failure, returns a JSON error with the specified status code
 func WriteErrJson(w http.ResponseWriter, status int, message string) error {
 	w.Header().Add("Content-Type", "application/json")
@@ -19,16 +40,211 @@ func WriteErrValidationJson(w http.ResponseWriter, validationErr IErrHttpValidat
 	return json.NewEncoder(w).Encode(map[string]any{"message": validationErr.Error(), "errors": validationErr.ValidationErrors()})
 }
 
-// Used for operations that resulted in a failure, returns a JSON error
+// Used for operations that resulted in a failure, returns an RFC 9457 problem details document
 // Determines the status code from the error if possible, defaults to 500
-func WriteErr(w http.ResponseWriter, err error) error {
-	if validationErr, ok := err.(IErrHttpValidation); ok {
-		return WriteErrValidationJson(w, validationErr)
-	} else if httpErr, ok := err.(IErrHttp); ok {
-		return WriteErrJson(w, httpErr.StatusCode(), httpErr.Error())
+func WriteErrProblemJson(w http.ResponseWriter, err error, opts ...ErrOpts) error {
+	var opt ErrOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	} else {
+		opt = DefaultErrOpts
+	}
+	return writeErrProblemJson(context.Background(), w, err, opt)
+}
+
+// WriteErrProblemJson with the context given to ErrOpts.Extensions
+func writeErrProblemJson(ctx context.Context, w http.ResponseWriter, err error, opt ErrOpts) error {
+	httpErr := MapErr(err)
+	status := httpErr.StatusCode()
+	problem := extensionsBody(ctx, httpErr, opt)
+	var problemType, title, detail, instance string
+	if problemErr, ok := httpErr.(IErrHttpProblem); ok {
+		// Extensions are added first so they can never override the standard members
+		for key, value := range problemErr.Extensions() {
+			problem[key] = value
+		}
+		problemType = problemErr.Type()
+		title = problemErr.Title()
+		detail = problemErr.Detail()
+		instance = problemErr.Instance()
+	}
+	if problemType == "" {
+		problemType = "about:blank"
+	}
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	if title == "" {
+		title = httpErr.Error()
+	}
+	if detail == "" {
+		detail = httpErr.Error()
+	}
+	problem["type"] = problemType
+	problem["title"] = title
+	problem["status"] = status
+	problem["detail"] = detail
+	if instance != "" {
+		problem["instance"] = instance
+	}
+	if validationErrs := validationErrBody(httpErr, opt); validationErrs != nil {
+		problem["errors"] = validationErrs
+	}
+	if messages := validationMessageBody(httpErr, opt); messages != nil {
+		problem["messages"] = messages
+	}
+	if id := errRequestID(ctx, w); id != "" {
+		problem["request_id"] = id
+	}
+	w.Header().Add("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+// Used for operations that resulted in a failure, returns a JSON error
+// Determines the status code from the error with MapErr, defaults to 500
+// The output format can be changed globally with DefaultErrOpts or per call by passing ErrOpts
+func WriteErr(w http.ResponseWriter, err error, opts ...ErrOpts) error {
+	var opt ErrOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	} else {
+		opt = DefaultErrOpts
+	}
+	return writeErr(context.Background(), w, err, opt)
+}
+
+// Used for operations that resulted in a failure, returns a JSON error like WriteErr
+// The cause is logged with the context, the request ID is read from it and it is given to ErrOpts.Extensions
+func WriteErrCtx(ctx context.Context, w http.ResponseWriter, err error, opts ...ErrOpts) error {
+	var opt ErrOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	} else {
+		opt = DefaultErrOpts
+	}
+	return writeErr(ctx, w, err, opt)
+}
+
+// WriteErr with the context the cause is logged and the error rendered with
+func writeErr(ctx context.Context, w http.ResponseWriter, err error, opt ErrOpts) error {
+	httpErr := MapErr(err)
+	logErrCause(ctx, err, httpErr)
+	if opt.ProblemDetails {
+		return writeErrProblemJson(ctx, w, httpErr, opt)
+	}
+	status, body := errBody(ctx, w, httpErr, opt)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(body)
+}
+
+// Log the underlying cause of an error, it is never sent to the client
+// The context lets a ContextHandler add the request attributes
+func logErrCause(ctx context.Context, err error, httpErr IErrHttp) {
+	cause := errors.Unwrap(httpErr)
+	if cause == nil && err.Error() == httpErr.Error() {
+		return
+	}
+	level := slog.LevelDebug
+	if httpErr.StatusCode() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	attrs := []any{slog.Int("status", httpErr.StatusCode()), slog.Any("err", err)}
+	if cause != nil && cause.Error() != err.Error() {
+		attrs = append(attrs, slog.Any("cause", cause))
+	}
+	slog.Log(ctx, level, "httpie.WriteErr", attrs...)
+}
+
+// Used for operations that resulted in a failure, encodes the error using the request Accept header
+// Falls back to WriteErr when JSON is selected, nothing is acceptable, or the encoder can't represent the error
+func WriteErrNegotiated(w http.ResponseWriter, r *http.Request, err error, opts ...ErrOpts) error {
+	var opt ErrOpts
+	if len(opts) > 0 {
+		opt = opts[0]
 	} else {
-		return WriteErrJson(w, http.StatusInternalServerError, "internal server error")
+		opt = DefaultErrOpts
 	}
+	mediaType, encoder, negotiateErr := DefaultCodecs.Negotiate(r.Header.Get("Accept"))
+	if negotiateErr != nil || mediaType == "application/json" || opt.ProblemDetails {
+		return writeErr(r.Context(), w, err, opt)
+	}
+	status, body := errBody(r.Context(), w, err, opt)
+	var buffer bytes.Buffer
+	if encodeErr := encoder.Encode(&buffer, body); encodeErr != nil {
+		return writeErr(r.Context(), w, err, opt)
+	}
+	logErrCause(r.Context(), err, MapErr(err))
+	w.Header().Add("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	_, err = w.Write(buffer.Bytes())
+	return err
+}
+
+// The status code and body WriteErr renders for an error, the detail and request ID are included when set
+func errBody(ctx context.Context, w http.ResponseWriter, err error, opt ErrOpts) (int, map[string]any) {
+	httpErr := MapErr(err)
+	body := extensionsBody(ctx, httpErr, opt)
+	body["message"] = httpErr.Error()
+	if validationErrs := validationErrBody(httpErr, opt); validationErrs != nil {
+		body["errors"] = validationErrs
+	}
+	if messages := validationMessageBody(httpErr, opt); messages != nil {
+		body["messages"] = messages
+	}
+	if problemErr, ok := httpErr.(IErrHttpProblem); ok && problemErr.Detail() != "" {
+		body["detail"] = problemErr.Detail()
+	}
+	if id := errRequestID(ctx, w); id != "" {
+		body["request_id"] = id
+	}
+	return httpErr.StatusCode(), body
+}
+
+// The members from ErrOpts.Extensions, the standard members are set afterwards so they can't be overridden
+func extensionsBody(ctx context.Context, httpErr IErrHttp, opt ErrOpts) map[string]any {
+	body := map[string]any{}
+	if opt.Extensions != nil {
+		for key, value := range opt.Extensions(ctx, httpErr) {
+			body[key] = value
+		}
+	}
+	return body
+}
+
+// The request ID from the context, or the one RequestIDMiddleware echoes on the response
+func errRequestID(ctx context.Context, w http.ResponseWriter) string {
+	if id := GetRequestID(ctx); id != "" {
+		return id
+	}
+	return responseRequestID(w)
+}
+
+// The validation errors to render, either the first failure or every failure for each field
+func validationErrBody(httpErr IErrHttp, opt ErrOpts) any {
+	if opt.ValidationErrorList {
+		if validationErr, ok := httpErr.(IErrHttpValidationList); ok {
+			return validationErr.ValidationErrorList()
+		}
+	}
+	if validationErr, ok := httpErr.(IErrHttpValidation); ok {
+		return validationErr.ValidationErrors()
+	}
+	return nil
+}
+
+// The validation messages to render, nil when the error has none
+func validationMessageBody(httpErr IErrHttp, opt ErrOpts) any {
+	messageErr, ok := httpErr.(IErrHttpValidationMessages)
+	if !ok || len(messageErr.ValidationMessageList()) == 0 {
+		return nil
+	}
+	if opt.ValidationErrorList {
+		return messageErr.ValidationMessageList()
+	}
+	return messageErr.ValidationMessages()
 }
 
 // Used for successful operations that return a body
@@ -38,6 +254,25 @@ func WriteOk[T any](w http.ResponseWriter, data T) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
+// Used for successful operations that return a body, encodes the body using the request Accept header
+// Responds with 406 not acceptable if no registered encoder matches
+func WriteOkNegotiated[T any](w http.ResponseWriter, r *http.Request, data T) error {
+	mediaType, encoder, err := DefaultCodecs.Negotiate(r.Header.Get("Accept"))
+	if err != nil {
+		return writeErr(r.Context(), w, err, DefaultErrOpts)
+	}
+	// Encode up front so a failure can still be reported with the correct status code
+	var buffer bytes.Buffer
+	if err := encoder.Encode(&buffer, data); err != nil {
+		return writeErr(r.Context(), w, err, DefaultErrOpts)
+	}
+	w.Header().Add("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buffer.Bytes())
+	return err
+}
+
 // Used for successful operations that dont return a body
 func WriteAccepted(w http.ResponseWriter) error {
 	w.WriteHeader(http.StatusNoContent)
@@ -45,10 +280,28 @@ func WriteAccepted(w http.ResponseWriter) error {
 }
 
 // Helper to write either an error or a successful response
-func WriteOkOrErr[T any](w http.ResponseWriter, data T, err error) {
+func WriteOkOrErr[T any](w http.ResponseWriter, data T, err error, opts ...ErrOpts) {
+	if err != nil {
+		WriteErr(w, err, opts...)
+		return
+	}
+	WriteOk(w, data)
+}
+
+// Helper to write either an error or a successful response, errors are written with WriteErrCtx
+func WriteOkOrErrCtx[T any](ctx context.Context, w http.ResponseWriter, data T, err error, opts ...ErrOpts) {
 	if err != nil {
-		WriteErr(w, err)
+		WriteErrCtx(ctx, w, err, opts...)
 		return
 	}
 	WriteOk(w, data)
 }
+
+// Helper to write either an error or a successful response using the request Accept header
+func WriteOkOrErrNegotiated[T any](w http.ResponseWriter, r *http.Request, data T, err error, opts ...ErrOpts) {
+	if err != nil {
+		WriteErrNegotiated(w, r, err, opts...)
+		return
+	}
+	WriteOkNegotiated(w, r, data)
+}
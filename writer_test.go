@@ -1,7 +1,12 @@
 package httpie
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -82,3 +87,260 @@ func TestWriteOkOrErrOk(t *testing.T) {
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
 	assert.JSONEq(t, `{"name":"hello"}`, w.Body.String())
 }
+
+func TestWriteErrCtx(t *testing.T) {
+	t.Parallel()
+	ctx := WithRequestID(WithRequestBag(context.Background()), "req-2")
+	SetBagValue(ctx, testTenantKey, &testTenant{ID: "acme"})
+	opts := ErrOpts{Extensions: func(ctx context.Context, err IErrHttp) map[string]any {
+		tenant, _ := GetBagValue(ctx, testTenantKey)
+		// Standard members can't be overridden
+		return map[string]any{"tenant": tenant.ID, "message": "overridden", "status": 0}
+	}}
+	w := httptest.NewRecorder()
+	WriteErrCtx(ctx, w, ErrNotFound, opts)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"not found","request_id":"req-2","tenant":"acme","status":0}`, w.Body.String())
+
+	opts.ProblemDetails = true
+	w = httptest.NewRecorder()
+	WriteErrCtx(ctx, w, ErrNotFound, opts)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","request_id":"req-2","tenant":"acme","message":"overridden"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteOkOrErrCtx(ctx, w, "", ErrNotFound)
+	assert.JSONEq(t, `{"message":"not found","request_id":"req-2"}`, w.Body.String())
+}
+
+func TestWriteErrProblemJson(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErrProblemJson(w, ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found"}`, w.Body.String())
+}
+
+func TestWriteErrProblemJsonMembers(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	err := NewErrHttp(http.StatusForbidden, "forbidden").
+		WithType("https://example.com/probs/out-of-credit").
+		WithTitle("You do not have enough credit.").
+		WithDetail("Your current balance is 30, but that costs 50.").
+		WithInstance("/account/12345/msgs/abc").
+		WithExtension("balance", 30).
+		WithExtension("status", 200)
+	WriteErr(w, err, ErrOpts{ProblemDetails: true})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type":"https://example.com/probs/out-of-credit",
+		"title":"You do not have enough credit.",
+		"status":403,
+		"detail":"Your current balance is 30, but that costs 50.",
+		"instance":"/account/12345/msgs/abc",
+		"balance":30
+	}`, w.Body.String())
+}
+
+func TestWriteErrProblemJsonValidation(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	err := NewErrHttpValidation(map[string]string{"name": "required"}).WithType("https://example.com/probs/validation")
+	WriteErr(w, err, ErrOpts{ProblemDetails: true})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{
+		"type":"https://example.com/probs/validation",
+		"title":"Bad Request",
+		"status":400,
+		"detail":"validation failed",
+		"errors":{"name":"required"}
+	}`, w.Body.String())
+}
+
+func TestWriteErrProblemJsonOther(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErr(w, errors.New("secret"), ErrOpts{ProblemDetails: true})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error"}`, w.Body.String())
+}
+
+func TestWriteOkNegotiatedXml(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "application/xml;q=0.9, application/json;q=0.1")
+	WriteOkNegotiated(w, r, testStruct{Name: "hello"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	assert.Contains(t, w.Body.String(), "<testStruct><Name>hello</Name></testStruct>")
+}
+
+func TestWriteOkNegotiatedDefault(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	WriteOkNegotiated(w, r, map[string]string{"message": "hello"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"hello"}`, w.Body.String())
+}
+
+func TestWriteOkNegotiatedNotAcceptable(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "text/html")
+	WriteOkNegotiated(w, r, map[string]string{"message": "hello"})
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.JSONEq(t, `{"message":"not acceptable"}`, w.Body.String())
+}
+
+func TestWriteOkNegotiatedEncodeErr(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "text/csv")
+	WriteOkNegotiated(w, r, map[string]string{"message": "hello"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestWriteErrNegotiatedXml(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "application/xml")
+	WriteErrNegotiated(w, r, NewErrHttpValidation(map[string]string{"name": "required"}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<response><entry key="errors"><entry key="name">required</entry></entry><entry key="message">validation failed</entry></response>`)
+}
+
+func TestWriteErrNegotiatedFallback(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "text/csv")
+	WriteErrNegotiated(w, r, ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"not found"}`, w.Body.String())
+}
+
+func TestWriteOkOrErrNegotiated(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	WriteOkOrErrNegotiated(w, r, []testStruct{{Name: "a"}}, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Name\na\n", w.Body.String())
+	w = httptest.NewRecorder()
+	WriteOkOrErrNegotiated(w, r, []testStruct{}, errors.New("test"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestWriteErrWrapped(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErr(w, fmt.Errorf("loading user: %w", ErrNotFound))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"not found"}`, w.Body.String())
+}
+
+func TestWriteErrJoined(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErr(w, errors.Join(errors.New("test"), NewErrHttpValidation(map[string]string{"name": "required"})))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"errors":{"name":"required"},"message":"validation failed"}`, w.Body.String())
+}
+
+func TestWriteErrCauseNotLeaked(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErr(w, ErrConflict.Wrap(NewErrHttpValidation(map[string]string{"secret": "leaked"})))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"message":"conflict"}`, w.Body.String())
+}
+
+func TestWriteErrProblemJsonWrapped(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErr(w, fmt.Errorf("wrapped: %w", ErrInternal.Wrap(errors.New("secret"))), ErrOpts{ProblemDetails: true})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret")
+	assert.NotContains(t, w.Body.String(), "wrapped")
+}
+
+func TestWriteErrDetail(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	WriteErr(w, ErrNotFound.WithDetail("user 42 does not exist"))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message":"not found","detail":"user 42 does not exist"}`, w.Body.String())
+}
+
+func TestWriteErrValidationErrorList(t *testing.T) {
+	t.Parallel()
+	err := NewErrHttpValidationList(map[string][]string{"items[0].price": {"required", "gt=0"}})
+	w := httptest.NewRecorder()
+	WriteErr(w, err, ErrOpts{ValidationErrorList: true})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"items[0].price":["required","gt=0"]}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteErr(w, err, ErrOpts{ValidationErrorList: true, ProblemDetails: true})
+	assert.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"validation failed","errors":{"items[0].price":["required","gt=0"]}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteErr(w, err)
+	assert.JSONEq(t, `{"message":"validation failed","errors":{"items[0].price":"required"}}`, w.Body.String())
+}
+
+// Not parallel, it changes the global DefaultErrOpts
+func TestWriteErrNegotiatedDefaultProblemDetails(t *testing.T) {
+	previous := DefaultErrOpts
+	DefaultErrOpts.ProblemDetails = true
+	defer func() { DefaultErrOpts = previous }()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r.Header.Set("Accept", "application/xml")
+	WriteErrNegotiated(w, r, ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+}
+
+// Not parallel, it changes the default slog logger
+func TestWriteErrNegotiatedLogsCause(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+	writer := bytes.NewBufferString("")
+	slog.SetDefault(slog.New(NewContextHandler(slog.NewJSONHandler(writer, nil))))
+
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	r = r.WithContext(WithRequestID(r.Context(), "req-7"))
+	r.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	WriteErrNegotiated(w, r, ErrInternal.Wrap(errors.New("db down")))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "db down")
+
+	var log struct {
+		Level     string
+		Msg       string
+		Cause     string
+		RequestID string `json:"request_id"`
+	}
+	assert.Nil(t, json.Unmarshal(writer.Bytes(), &log))
+	assert.Equal(t, "ERROR", log.Level)
+	assert.Equal(t, "httpie.WriteErr", log.Msg)
+	assert.Equal(t, "db down", log.Cause)
+	// The request context reaches the ContextHandler
+	assert.Equal(t, "req-7", log.RequestID)
+}